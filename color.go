@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// colorMode says how the values of the channel trees become a color. In
+// colorPalette only the first tree is used, mapped through a gradient.
+type colorMode int
+
+const (
+	colorRGB colorMode = iota
+	colorHSV
+	colorHSL
+	colorOKLab
+	colorYCbCr
+	colorPalette
+)
+
+var colorModes = []colorMode{colorRGB, colorHSV, colorHSL, colorOKLab, colorYCbCr, colorPalette}
+
+func (m colorMode) String() string {
+	switch m {
+	case colorRGB:
+		return "rgb"
+	case colorHSV:
+		return "hsv"
+	case colorHSL:
+		return "hsl"
+	case colorOKLab:
+		return "oklab"
+	case colorYCbCr:
+		return "ycbcr"
+	case colorPalette:
+		return "palette"
+	}
+	return "unknown"
+}
+
+// next returns the mode following m, wrapping around after the last one.
+func (m colorMode) next() colorMode {
+	return colorModes[(int(m)+1)%len(colorModes)]
+}
+
+func parseColorMode(name string) (colorMode, error) {
+	for _, m := range colorModes {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown color mode %q", name)
+}
+
+// toRGB interprets the channel values a, b and c, each in [0,1], in the
+// color model m. The result is opaque.
+func (m colorMode) toRGB(a, b, c float32) floatColor {
+	var r, g, bl float32
+	switch m {
+	case colorHSV:
+		r, g, bl = hsvToRGB(a, b, c)
+	case colorHSL:
+		r, g, bl = hslToRGB(a, b, c)
+	case colorOKLab:
+		// a and b of OKLab stay within about [-0.4,0.4] for visible colors.
+		r, g, bl = oklabToRGB(a, (b-0.5)*0.8, (c-0.5)*0.8)
+	case colorYCbCr:
+		// Full range YCbCr, as in JPEG.
+		r = a + 1.402*(c-0.5)
+		g = a - 0.344136*(b-0.5) - 0.714136*(c-0.5)
+		bl = a + 1.772*(b-0.5)
+	default:
+		r, g, bl = a, b, c
+	}
+	return floatColor{clamp01(r), clamp01(g), clamp01(bl), 1}
+}
+
+func clamp01(f float32) float32 {
+	return min(max(f, 0), 1)
+}
+
+func hsvToRGB(h, s, v float32) (float32, float32, float32) {
+	c := v * s
+	return hueToRGB(h, c, v-c)
+}
+
+func hslToRGB(h, s, l float32) (float32, float32, float32) {
+	c := (1 - abs32(2*l-1)) * s
+	return hueToRGB(h, c, l-c/2)
+}
+
+// hueToRGB returns the color of hue h in [0,1] with chroma c, lightened by m.
+func hueToRGB(h, c, m float32) (float32, float32, float32) {
+	h6 := (h - float32(math.Floor(float64(h)))) * 6
+	x := c * (1 - abs32(float32(math.Mod(float64(h6), 2))-1))
+	var r, g, b float32
+	switch {
+	case h6 < 1:
+		r, g, b = c, x, 0
+	case h6 < 2:
+		r, g, b = x, c, 0
+	case h6 < 3:
+		r, g, b = 0, c, x
+	case h6 < 4:
+		r, g, b = 0, x, c
+	case h6 < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return r + m, g + m, b + m
+}
+
+func oklabToRGB(l, a, b float32) (float32, float32, float32) {
+	l1 := l + 0.3963377774*a + 0.2158037573*b
+	m1 := l - 0.1055613458*a - 0.0638541728*b
+	s1 := l - 0.0894841775*a - 1.2914855480*b
+	l3, m3, s3 := l1*l1*l1, m1*m1*m1, s1*s1*s1
+
+	r := 4.0767416621*l3 - 3.3077115913*m3 + 0.2309699292*s3
+	g := -1.2684380046*l3 + 2.6097574011*m3 - 0.3413193965*s3
+	bl := -0.0041960863*l3 - 0.7034186147*m3 + 1.7076147010*s3
+	return srgbEncode(r), srgbEncode(g), srgbEncode(bl)
+}
+
+// srgbEncode applies the sRGB transfer function to a linear value.
+func srgbEncode(f float32) float32 {
+	if f <= 0.0031308 {
+		return 12.92 * f
+	}
+	return 1.055*float32(math.Pow(float64(f), 1/2.4)) - 0.055
+}
+
+// formatArg formats a number of a setting so it parses back to the same
+// float32.
+func formatArg(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
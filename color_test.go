@@ -0,0 +1,121 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// rgbBytes returns the 8-bit color of a, b and c in the color model m.
+func rgbBytes(m colorMode, a, b, c float32) [3]uint8 {
+	rgb := m.toRGB(a, b, c)
+	return [3]uint8{toByte(rgb[0]), toByte(rgb[1]), toByte(rgb[2])}
+}
+
+// nearBytes reports whether no component of a and b differs by more than
+// tolerance.
+func nearBytes(a, b [3]uint8, tolerance int) bool {
+	for i := range a {
+		if d := int(a[i]) - int(b[i]); d < -tolerance || d > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+func TestColorModesAtKnownColors(t *testing.T) {
+	red, green, blue := [3]uint8{255, 0, 0}, [3]uint8{0, 255, 0}, [3]uint8{0, 0, 255}
+	white, black, gray := [3]uint8{255, 255, 255}, [3]uint8{0, 0, 0}, [3]uint8{128, 128, 128}
+	tests := []struct {
+		mode    colorMode
+		a, b, c float32
+		want    [3]uint8
+	}{
+		{colorRGB, 1, 0, 0, red},
+		{colorRGB, 2, -1, 0.5, [3]uint8{255, 0, 128}},
+		{colorHSV, 0, 1, 1, red},
+		{colorHSV, 1.0 / 3, 1, 1, green},
+		{colorHSV, 2.0 / 3, 1, 1, blue},
+		{colorHSV, 1, 1, 1, red},
+		{colorHSV, -1.0 / 6, 1, 1, [3]uint8{255, 0, 255}},
+		{colorHSV, 0.3, 0, 0.5, gray},
+		{colorHSV, 0.3, 1, 0, black},
+		{colorHSL, 0, 1, 0.5, red},
+		{colorHSL, 1.0 / 3, 1, 0.5, green},
+		{colorHSL, 2.0 / 3, 1, 0.5, blue},
+		{colorHSL, 0.3, 1, 1, white},
+		{colorHSL, 0.3, 1, 0, black},
+		{colorHSL, 0.3, 0, 0.5, gray},
+		{colorOKLab, 1, 0.5, 0.5, white},
+		{colorOKLab, 0, 0.5, 0.5, black},
+		// sRGB red, green and blue in OKLab, with a and b mapped to [0,1].
+		{colorOKLab, 0.627955, 0.224863/0.8 + 0.5, 0.125846/0.8 + 0.5, red},
+		{colorOKLab, 0.866440, -0.233888/0.8 + 0.5, 0.179498/0.8 + 0.5, green},
+		{colorOKLab, 0.452014, -0.032457/0.8 + 0.5, -0.311528/0.8 + 0.5, blue},
+		{colorYCbCr, 1, 0.5, 0.5, white},
+		{colorYCbCr, 0.5, 0.5, 0.5, gray},
+		{colorYCbCr, 0.299, 0.5 - 0.168736, 1, red},
+	}
+	for _, test := range tests {
+		if got := rgbBytes(test.mode, test.a, test.b, test.c); !nearBytes(got, test.want, 2) {
+			t.Errorf("%s %v, %v, %v gives %v, want %v", test.mode, test.a, test.b, test.c, got, test.want)
+		}
+	}
+}
+
+// rgbToHSV is the inverse of hsvToRGB, with hue in [0,1).
+func rgbToHSV(r, g, b float32) (float32, float32, float32) {
+	v := max(r, g, b)
+	c := v - min(r, g, b)
+	if c == 0 {
+		return 0, 0, v
+	}
+	var h float32
+	switch v {
+	case r:
+		h = (g - b) / c
+	case g:
+		h = (b-r)/c + 2
+	default:
+		h = (r-g)/c + 4
+	}
+	h /= 6
+	if h < 0 {
+		h++
+	}
+	return h, c / v, v
+}
+
+// rgbToOKLab is the inverse of oklabToRGB.
+func rgbToOKLab(r, g, b float32) (float32, float32, float32) {
+	decode := func(f float32) float64 {
+		if f <= 0.04045 {
+			return float64(f) / 12.92
+		}
+		return math.Pow((float64(f)+0.055)/1.055, 2.4)
+	}
+	lr, lg, lb := decode(r), decode(g), decode(b)
+	l := math.Cbrt(0.4122214708*lr + 0.5363325363*lg + 0.0514459929*lb)
+	m := math.Cbrt(0.2119034982*lr + 0.6806995451*lg + 0.1073969566*lb)
+	s := math.Cbrt(0.0883024619*lr + 0.2817188376*lg + 0.6299787005*lb)
+	return float32(0.2104542553*l + 0.7936177850*m - 0.0040720468*s),
+		float32(1.9779984951*l - 2.4285922050*m + 0.4505937099*s),
+		float32(0.0259040371*l + 0.7827717662*m - 0.8086757660*s)
+}
+
+func TestColorRoundTrips(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 1000 {
+		want := [3]uint8{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256))}
+		r, g, b := float32(want[0])/255, float32(want[1])/255, float32(want[2])/255
+		h, s, v := rgbToHSV(r, g, b)
+		if got := rgbBytes(colorHSV, h, s, v); got != want {
+			t.Errorf("%v through HSV %v, %v, %v is %v", want, h, s, v, got)
+		}
+		l, a, bl := rgbToOKLab(r, g, b)
+		r2, g2, b2 := oklabToRGB(l, a, bl)
+		if got := [3]uint8{toByte(r2), toByte(g2), toByte(b2)}; got != want {
+			t.Errorf("%v through OKLab %v, %v, %v is %v", want, l, a, bl, got)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import eqt "github.com/toantht/texturegen/equation"
+
+type crossoverMode int
+
+const (
+	crossoverSubtree crossoverMode = iota
+	crossoverChannelSwap
+	crossoverOnePoint
+	crossoverUniform
+)
+
+var crossoverModes = []crossoverMode{crossoverSubtree, crossoverChannelSwap, crossoverOnePoint, crossoverUniform}
+
+func (m crossoverMode) String() string {
+	switch m {
+	case crossoverSubtree:
+		return "subtree"
+	case crossoverChannelSwap:
+		return "channel"
+	case crossoverOnePoint:
+		return "one-point"
+	case crossoverUniform:
+		return "uniform"
+	}
+	return "unknown"
+}
+
+// next returns the mode following m, wrapping around after the last one.
+func (m crossoverMode) next() crossoverMode {
+	return crossoverModes[(int(m)+1)%len(crossoverModes)]
+}
+
+// crossover builds a child from a and b. Neither parent is modified and the
+// child never shares nodes with them.
+func crossover(a *textureEquation, b *textureEquation, mode crossoverMode, rng eqt.Rand) *textureEquation {
+	switch mode {
+	case crossoverSubtree:
+		return subtreeCrossover(a, b, rng)
+	case crossoverChannelSwap:
+		return channelSwapCrossover(a, b, rng)
+	case crossoverOnePoint:
+		return onePointCrossover(a, b, rng)
+	case crossoverUniform:
+		return uniformCrossover(a, b, rng)
+	}
+	panic("unknown crossover mode")
+}
+
+// subtreeCrossover grafts a copy of a random subtree from a random channel of
+// b onto a random node of a random channel of a.
+func subtreeCrossover(a *textureEquation, b *textureEquation, rng eqt.Rand) *textureEquation {
+	child := copyTextureEquation(a)
+	channels := child.channels()
+	channel := channels[rng.Intn(len(channels))]
+	aNode := eqt.PickRandomNode(*channel, rng)
+
+	bNode := eqt.CopyTree(eqt.PickRandomNode(b.pickRandomColor(rng), rng))
+
+	graft(channel, aNode, bNode)
+	return child
+}
+
+// channelSwapCrossover takes every channel as a whole from either a or b.
+func channelSwapCrossover(a *textureEquation, b *textureEquation, rng eqt.Rand) *textureEquation {
+	child := a.copySettings()
+	aChannels, bChannels := a.channels(), b.channels()
+	for i, channel := range child.channels() {
+		source := aChannels[i]
+		if rng.Intn(2) == 1 {
+			source = bChannels[i]
+		}
+		*channel = eqt.CopyTree(*source)
+	}
+	child.a = crossAlpha(a, b, rng)
+	return child
+}
+
+// crossAlpha returns a copy of the alpha channel of a or b. The child is
+// opaque when the chosen parent is.
+func crossAlpha(a *textureEquation, b *textureEquation, rng eqt.Rand) eqt.BaseNode {
+	if a.a == nil && b.a == nil {
+		return nil
+	}
+	if rng.Intn(2) == 0 {
+		return eqt.CopyTree(a.a)
+	}
+	return eqt.CopyTree(b.a)
+}
+
+// onePointCrossover picks a point inside the region where the trees of one
+// channel have the same shape and replaces the subtree of a at that point with
+// the subtree of b at the same position.
+func onePointCrossover(a *textureEquation, b *textureEquation, rng eqt.Rand) *textureEquation {
+	child := copyTextureEquation(a)
+	channels := child.channels()
+	i := rng.Intn(min(len(channels), len(b.channels())))
+
+	region := commonRegion(*channels[i], *b.channels()[i])
+	pair := region[rng.Intn(len(region))]
+
+	graft(channels[i], pair[0], eqt.CopyTree(pair[1]))
+	return child
+}
+
+// uniformCrossover walks the common region of every channel. Interior nodes
+// take their operation from either parent, boundary nodes take the whole
+// subtree from either parent.
+func uniformCrossover(a *textureEquation, b *textureEquation, rng eqt.Rand) *textureEquation {
+	child := a.copySettings()
+	aChannels, bChannels := a.channels(), b.channels()
+	for i, channel := range child.channels() {
+		*channel = uniformMerge(*aChannels[i], *bChannels[i], rng)
+	}
+	if a.a != nil && b.a != nil {
+		child.a = uniformMerge(a.a, b.a, rng)
+	} else {
+		child.a = crossAlpha(a, b, rng)
+	}
+	return child
+}
+
+func uniformMerge(a eqt.BaseNode, b eqt.BaseNode, rng eqt.Rand) eqt.BaseNode {
+	aChildren, bChildren := a.GetChildren(), b.GetChildren()
+	if len(aChildren) == 0 || len(aChildren) != len(bChildren) {
+		if rng.Intn(2) == 0 {
+			return eqt.CopyTree(a)
+		}
+		return eqt.CopyTree(b)
+	}
+
+	source := a
+	if rng.Intn(2) == 1 {
+		source = b
+	}
+	node := eqt.CopyNode(source)
+	for i := range aChildren {
+		child := uniformMerge(aChildren[i], bChildren[i], rng)
+		node.GetChildren()[i] = child
+		child.SetParent(node)
+	}
+	return node
+}
+
+// commonRegion returns the pairs of nodes at the same position in a and b,
+// descending only through nodes with the same number of children.
+func commonRegion(a eqt.BaseNode, b eqt.BaseNode) [][2]eqt.BaseNode {
+	region := [][2]eqt.BaseNode{{a, b}}
+	aChildren, bChildren := a.GetChildren(), b.GetChildren()
+	if len(aChildren) == len(bChildren) {
+		for i := range aChildren {
+			region = append(region, commonRegion(aChildren[i], bChildren[i])...)
+		}
+	}
+	return region
+}
+
+// graft replaces old with new inside the tree rooted at *root, updating the
+// root itself when old is the root.
+func graft(root *eqt.BaseNode, old eqt.BaseNode, new eqt.BaseNode) {
+	if old == *root {
+		*root = new
+		new.SetParent(nil)
+		return
+	}
+	eqt.ReplaceNode(old, new)
+}
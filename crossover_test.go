@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math/rand"
+	"testing"
+
+	eqt "github.com/toantht/texturegen/equation"
+)
+
+// collectNodes adds every node of the channels of t to nodes.
+func collectNodes(t *textureEquation, nodes map[eqt.BaseNode]bool) {
+	var visit func(node eqt.BaseNode)
+	visit = func(node eqt.BaseNode) {
+		nodes[node] = true
+		for _, child := range node.GetChildren() {
+			visit(child)
+		}
+	}
+	for _, channel := range t.channels() {
+		visit(*channel)
+	}
+}
+
+func TestCrossoverLeavesParentsAlone(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, mode := range crossoverModes {
+		for i := 0; i < 200; i++ {
+			a, b := NewTextureEquation(rng), NewTextureEquation(rng)
+			// Every third pair has an alpha channel on one parent only.
+			switch i % 3 {
+			case 1:
+				a.a = randomEquation(rng.Intn(10)+1, rng)
+			case 2:
+				b.a = randomEquation(rng.Intn(10)+1, rng)
+			}
+			before := [2]string{a.String(), b.String()}
+
+			child := crossover(a, b, mode, rng)
+
+			if a.String() != before[0] || b.String() != before[1] {
+				t.Fatalf("%s crossover %d modified a parent", mode, i)
+			}
+			parents := make(map[eqt.BaseNode]bool)
+			collectNodes(a, parents)
+			collectNodes(b, parents)
+			nodes := make(map[eqt.BaseNode]bool)
+			collectNodes(child, nodes)
+			for node := range nodes {
+				if parents[node] {
+					t.Fatalf("%s crossover %d shares %s with a parent", mode, i, node)
+				}
+			}
+		}
+	}
+}
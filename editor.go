@@ -0,0 +1,220 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"strconv"
+	"strings"
+
+	eqt "github.com/toantht/texturegen/equation"
+	"github.com/toantht/texturegen/gui"
+)
+
+var editorBackground = color.RGBA{20, 20, 24, 230}
+
+// editorRow is a line of the tree view. index is the position of node in
+// its channel as counted by eqt.GetNthNode.
+type editorRow struct {
+	channel int
+	index   int
+	depth   int
+	node    eqt.BaseNode
+}
+
+// editor edits the trees of an equation in place. Nodes are selected in a
+// collapsible tree view, then replaced by another operation, given a new
+// constant value, or cut, copied and pasted between channels. onChange is
+// called after every edit.
+type editor struct {
+	equation  *textureEquation
+	onChange  func()
+	collapsed map[eqt.BaseNode]bool
+	clipboard eqt.BaseNode
+	rows      []editorRow
+
+	// The selected node is kept as a channel and an index rather than a
+	// node, so it stays selected when the node is replaced.
+	channel, index int
+
+	screen *gui.Screen
+	panel  *gui.Panel
+	tree   *gui.List
+	ops    *gui.Dropdown
+	value  *gui.Slider
+	text   *gui.TextInput
+}
+
+func newEditor(equation *textureEquation, onChange func()) *editor {
+	e := &editor{equation: equation, onChange: onChange, collapsed: map[eqt.BaseNode]bool{}}
+
+	e.tree = gui.NewList(nil, e.selectRow, e.toggleRow)
+
+	names := make([]string, len(eqt.Ops))
+	for i, op := range eqt.Ops {
+		names[i] = op.Name
+	}
+	e.ops = gui.NewDropdown(names, 0, e.replaceOp)
+	e.ops.OpenUp = true
+
+	e.value = gui.NewSlider("value", -1, 1, 0, 0, func(value float64) {
+		e.setConstant(float32(value))
+	})
+	e.text = gui.NewTextInput("", func(text string) {
+		if value, err := strconv.ParseFloat(text, 32); err == nil {
+			e.setConstant(float32(value))
+		}
+	})
+	e.text.Width = 70
+
+	controls := gui.NewPanel(gui.Row, e.ops, e.value)
+	clipboard := gui.NewPanel(gui.Row,
+		e.text,
+		gui.NewButton("Cut", e.cut),
+		gui.NewButton("Copy", e.copy),
+		gui.NewButton("Paste", e.paste),
+		gui.NewButton("Del", e.delete),
+	)
+	e.panel = gui.NewPanel(gui.Column, e.tree, controls, clipboard)
+	e.panel.Padding = 4
+	e.panel.Background = editorBackground
+	e.screen = gui.NewScreen(e.panel)
+
+	e.refresh()
+	return e
+}
+
+// layout gives the editor the left part of a screen of the given size.
+func (e *editor) layout(width, height int) {
+	width = max(width*11/20, 240)
+	e.tree.Width = width - 8
+	e.tree.Height = height - 60
+	opsWidth, _ := e.ops.PreferredSize()
+	e.value.Width = width - 8 - e.panel.Spacing - opsWidth
+	e.screen.SetBounds(image.Rect(0, 0, width, height))
+}
+
+func (e *editor) update(input gui.Input) {
+	e.screen.Update(input)
+}
+
+func (e *editor) selected() eqt.BaseNode {
+	return eqt.GetNthNode(*e.equation.channels()[e.channel], e.index)
+}
+
+// refresh rebuilds the tree view and sets the controls to the selected node.
+func (e *editor) refresh() {
+	e.rows = e.rows[:0]
+	items := make([]string, 0)
+	for c, channel := range e.equation.channels() {
+		index := 0
+		var walk func(node eqt.BaseNode, depth int)
+		walk = func(node eqt.BaseNode, depth int) {
+			row := editorRow{c, index, depth, node}
+			e.rows = append(e.rows, row)
+			items = append(items, e.label(row))
+			if e.channel == c && e.index == index {
+				e.tree.Selected = len(e.rows) - 1
+			}
+
+			if e.collapsed[node] {
+				index += node.NodeCount()
+				return
+			}
+			index++
+			for _, child := range node.GetChildren() {
+				walk(child, depth+1)
+			}
+		}
+		walk(*channel, 0)
+	}
+	e.tree.Items = items
+
+	node := e.selected()
+	e.ops.Selected = eqt.OpIndex(node)
+	if constant, ok := node.(*eqt.OpConstant); ok {
+		e.value.Value = float64(constant.Value())
+		e.text.Text = strconv.FormatFloat(float64(constant.Value()), 'g', -1, 32)
+	}
+}
+
+func (e *editor) label(row editorRow) string {
+	marker := "  "
+	if len(row.node.GetChildren()) > 0 {
+		marker = "- "
+		if e.collapsed[row.node] {
+			marker = "+ "
+		}
+	}
+	prefix := "  "
+	if row.depth == 0 {
+		prefix = []string{"R ", "G ", "B ", "A "}[row.channel]
+	}
+	return prefix + strings.Repeat("  ", row.depth) + marker + eqt.Name(row.node)
+}
+
+func (e *editor) selectRow(i int) {
+	e.channel, e.index = e.rows[i].channel, e.rows[i].index
+	e.refresh()
+}
+
+func (e *editor) toggleRow(i int) {
+	node := e.rows[i].node
+	if len(node.GetChildren()) > 0 {
+		e.channel, e.index = e.rows[i].channel, e.rows[i].index
+		e.collapsed[node] = !e.collapsed[node]
+		e.refresh()
+	}
+}
+
+// replace puts node in place of the selected node.
+func (e *editor) replace(node eqt.BaseNode) {
+	graft(e.equation.channels()[e.channel], e.selected(), node)
+	e.refresh()
+	e.onChange()
+}
+
+// replaceOp swaps the operation of the selected node, keeping as many of
+// its children as the new operation takes.
+func (e *editor) replaceOp(i int) {
+	old := e.selected()
+	node := eqt.Ops[i].New()
+	for i := range node.GetChildren() {
+		var child eqt.BaseNode
+		if i < len(old.GetChildren()) {
+			child = old.GetChildren()[i]
+		} else {
+			child = eqt.NewOpConstant(0)
+		}
+		node.GetChildren()[i] = child
+		child.SetParent(node)
+	}
+	e.replace(node)
+}
+
+func (e *editor) setConstant(value float32) {
+	if constant, ok := e.selected().(*eqt.OpConstant); ok {
+		constant.SetValue(value)
+		e.refresh()
+		e.onChange()
+	}
+}
+
+func (e *editor) cut() {
+	e.copy()
+	e.delete()
+}
+
+func (e *editor) copy() {
+	e.clipboard = eqt.CopyTree(e.selected())
+}
+
+func (e *editor) paste() {
+	if e.clipboard != nil {
+		e.replace(eqt.CopyTree(e.clipboard))
+	}
+}
+
+// delete replaces the selected subtree with the constant 0.
+func (e *editor) delete() {
+	e.replace(eqt.NewOpConstant(0))
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"image/png"
+	"io"
+	"math"
+	"os"
+)
+
+type imageFormat int
+
+const (
+	formatPNG imageFormat = iota
+	formatPNG16
+	formatPFM
+	formatEXR
+)
+
+var imageFormats = []imageFormat{formatPNG, formatPNG16, formatPFM, formatEXR}
+
+func (f imageFormat) String() string {
+	switch f {
+	case formatPNG:
+		return "png"
+	case formatPNG16:
+		return "png16"
+	case formatPFM:
+		return "pfm"
+	case formatEXR:
+		return "exr"
+	}
+	return "unknown"
+}
+
+func (f imageFormat) extension() string {
+	switch f {
+	case formatPFM:
+		return ".pfm"
+	case formatEXR:
+		return ".exr"
+	}
+	return ".png"
+}
+
+func parseImageFormat(name string) (imageFormat, error) {
+	for _, f := range imageFormats {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown image format %q", name)
+}
+
+type exrCompression int
+
+const (
+	exrNone exrCompression = iota
+	exrZIP
+)
+
+func (c exrCompression) String() string {
+	switch c {
+	case exrNone:
+		return "none"
+	case exrZIP:
+		return "zip"
+	}
+	return "unknown"
+}
+
+func parseEXRCompression(name string) (exrCompression, error) {
+	for _, c := range []exrCompression{exrNone, exrZIP} {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown exr compression %q", name)
+}
+
+// writeImage writes img to filename in format. compression is only used by
+// formatEXR.
+func writeImage(filename string, img *floatImage, format imageFormat, compression exrCompression) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	switch format {
+	case formatPNG:
+		err = png.Encode(w, img.rgba())
+	case formatPNG16:
+		err = png.Encode(w, img.rgba64())
+	case formatPFM:
+		err = writePFM(w, img)
+	case formatEXR:
+		err = writeEXR(w, img, compression)
+	}
+	if err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// writePFM writes the color of img, without alpha, as a little endian
+// Portable Float Map. Rows go from the bottom of the image to the top.
+func writePFM(w io.Writer, img *floatImage) error {
+	if _, err := fmt.Fprintf(w, "PF\n%d %d\n-1.0\n", img.width, img.height); err != nil {
+		return err
+	}
+	row := make([]float32, img.width*3)
+	for y := img.height - 1; y >= 0; y-- {
+		for x := 0; x < img.width; x++ {
+			c := unpremultiply(img.at(x, y))
+			copy(row[x*3:], c[:3])
+		}
+		if err := binary.Write(w, binary.LittleEndian, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func unpremultiply(c floatColor) floatColor {
+	if c[3] == 0 || c[3] == 1 {
+		return c
+	}
+	return floatColor{c[0] / c[3], c[1] / c[3], c[2] / c[3], c[3]}
+}
+
+// exrLinesPerBlock is the number of scanlines compressed together by ZIP.
+const exrLinesPerBlock = 16
+
+// writeEXR writes img as a scanline OpenEXR file with 32-bit float channels.
+// The alpha channel is only written when img is not opaque.
+func writeEXR(w io.Writer, img *floatImage, compression exrCompression) error {
+	channels := []string{"B", "G", "R"}
+	components := []int{2, 1, 0}
+	if !img.opaque() {
+		// Channels are sorted by name.
+		channels = append([]string{"A"}, channels...)
+		components = append([]int{3}, components...)
+	}
+
+	linesPerBlock := 1
+	if compression == exrZIP {
+		linesPerBlock = exrLinesPerBlock
+	}
+
+	var header bytes.Buffer
+	le := binary.LittleEndian
+	header.Write([]byte{0x76, 0x2f, 0x31, 0x01})
+	binary.Write(&header, le, uint32(2))
+
+	attribute := func(name, typ string, value []byte) {
+		header.WriteString(name + "\x00" + typ + "\x00")
+		binary.Write(&header, le, uint32(len(value)))
+		header.Write(value)
+	}
+	var chlist bytes.Buffer
+	for _, name := range channels {
+		chlist.WriteString(name + "\x00")
+		// Pixel type float, not linear, reserved, x and y sampling.
+		binary.Write(&chlist, le, []int32{2, 0, 1, 1})
+	}
+	chlist.WriteByte(0)
+	attribute("channels", "chlist", chlist.Bytes())
+
+	compressionByte := byte(0)
+	if compression == exrZIP {
+		compressionByte = 3
+	}
+	attribute("compression", "compression", []byte{compressionByte})
+	window := le.AppendUint32(nil, 0)
+	window = le.AppendUint32(window, 0)
+	window = le.AppendUint32(window, uint32(img.width-1))
+	window = le.AppendUint32(window, uint32(img.height-1))
+	attribute("dataWindow", "box2i", window)
+	attribute("displayWindow", "box2i", window)
+	attribute("lineOrder", "lineOrder", []byte{0})
+	attribute("pixelAspectRatio", "float", le.AppendUint32(nil, math.Float32bits(1)))
+	attribute("screenWindowCenter", "v2f", make([]byte, 8))
+	attribute("screenWindowWidth", "float", le.AppendUint32(nil, math.Float32bits(1)))
+	header.WriteByte(0)
+
+	blocks := make([][]byte, 0)
+	for y := 0; y < img.height; y += linesPerBlock {
+		var data bytes.Buffer
+		for line := y; line < min(y+linesPerBlock, img.height); line++ {
+			for _, component := range components {
+				for x := 0; x < img.width; x++ {
+					binary.Write(&data, le, img.at(x, line)[component])
+				}
+			}
+		}
+		block := data.Bytes()
+		if compression == exrZIP {
+			compressed, err := exrZIPCompress(block)
+			if err != nil {
+				return err
+			}
+			// Blocks that do not shrink are stored uncompressed.
+			if len(compressed) < len(block) {
+				block = compressed
+			}
+		}
+		blocks = append(blocks, block)
+	}
+
+	offset := uint64(header.Len() + len(blocks)*8)
+	for _, block := range blocks {
+		binary.Write(&header, le, offset)
+		offset += uint64(8 + len(block))
+	}
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return err
+	}
+	for i, block := range blocks {
+		chunk := le.AppendUint32(nil, uint32(i*linesPerBlock))
+		chunk = le.AppendUint32(chunk, uint32(len(block)))
+		if _, err := w.Write(append(chunk, block...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exrZIPCompress splits the even and odd bytes of data, replaces every byte
+// with its difference to the previous one and deflates the result, as the
+// ZIP compression of OpenEXR does.
+func exrZIPCompress(data []byte) ([]byte, error) {
+	tmp := make([]byte, len(data))
+	half := (len(data) + 1) / 2
+	for i, b := range data {
+		if i%2 == 0 {
+			tmp[i/2] = b
+		} else {
+			tmp[half+i/2] = b
+		}
+	}
+	for i := len(tmp) - 1; i > 0; i-- {
+		tmp[i] = byte(int(tmp[i]) - int(tmp[i-1]) + 128)
+	}
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(tmp); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return compressed.Bytes(), nil
+}
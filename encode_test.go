@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"testing"
+)
+
+// testImage is a smooth gradient, which ZIP compresses, with a translucent
+// pixel unless opaque is set.
+func testImage(width, height int, opaque bool) *floatImage {
+	img := newFloatImage(width, height)
+	for y := range height {
+		for x := range width {
+			fx, fy := float32(x)/float32(width), float32(y)/float32(height)
+			img.set(x, y, floatColor{fx, fy, fx * fy, 1})
+		}
+	}
+	if !opaque {
+		img.set(1, 2, floatColor{0.25, 0.125, 0.5, 0.5})
+	}
+	return img
+}
+
+func TestWritePFM(t *testing.T) {
+	img := testImage(5, 3, false)
+	var b bytes.Buffer
+	if err := writePFM(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	const header = "PF\n5 3\n-1.0\n"
+	if !strings.HasPrefix(b.String(), header) {
+		t.Fatalf("header %q, want %q", b.String()[:len(header)], header)
+	}
+	pixels := make([]float32, 5*3*3)
+	if err := binary.Read(bytes.NewReader(b.Bytes()[len(header):]), binary.LittleEndian, pixels); err != nil {
+		t.Fatal(err)
+	}
+	if extra := b.Len() - len(header) - 4*len(pixels); extra != 0 {
+		t.Errorf("%d bytes after the pixels", extra)
+	}
+	for y := range 3 {
+		for x := range 5 {
+			want := unpremultiply(img.at(x, y))
+			// Rows are stored bottom up.
+			i := ((2-y)*5 + x) * 3
+			if got := pixels[i : i+3]; got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+				t.Errorf("pixel %d, %d is %v, want %v", x, y, got, want[:3])
+			}
+		}
+	}
+}
+
+// exrFile is what readEXR finds in a file written by writeEXR.
+type exrFile struct {
+	attributes map[string][]byte
+	channels   []string
+	// compressed counts the blocks stored deflated.
+	compressed int
+	pixels     map[string][]float32
+}
+
+// readEXR reads a single part scanline OpenEXR file with float channels and
+// no or ZIP compression.
+func readEXR(data []byte) (*exrFile, error) {
+	le := binary.LittleEndian
+	if len(data) < 8 || !bytes.Equal(data[:4], []byte{0x76, 0x2f, 0x31, 0x01}) || le.Uint32(data[4:]) != 2 {
+		return nil, fmt.Errorf("bad magic number or version % x", data[:min(8, len(data))])
+	}
+	f := &exrFile{attributes: make(map[string][]byte), pixels: make(map[string][]float32)}
+	r := bytes.NewReader(data[8:])
+	readString := func() string {
+		var s []byte
+		for {
+			c, err := r.ReadByte()
+			if err != nil || c == 0 {
+				return string(s)
+			}
+			s = append(s, c)
+		}
+	}
+	types := make(map[string]string)
+	for {
+		name := readString()
+		if name == "" {
+			break
+		}
+		types[name] = readString()
+		var size uint32
+		if err := binary.Read(r, le, &size); err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		if _, err := io.ReadFull(r, value); err != nil {
+			return nil, err
+		}
+		f.attributes[name] = value
+	}
+	if types["channels"] != "chlist" || types["compression"] != "compression" || types["dataWindow"] != "box2i" {
+		return nil, fmt.Errorf("attribute types %v", types)
+	}
+
+	chlist := f.attributes["channels"]
+	for len(chlist) > 1 {
+		end := bytes.IndexByte(chlist, 0)
+		f.channels = append(f.channels, string(chlist[:end]))
+		if pixelType := le.Uint32(chlist[end+1:]); pixelType != 2 {
+			return nil, fmt.Errorf("channel %s has pixel type %d", chlist[:end], pixelType)
+		}
+		chlist = chlist[end+1+16:]
+	}
+	window := f.attributes["dataWindow"]
+	width, height := int(le.Uint32(window[8:]))+1, int(le.Uint32(window[12:]))+1
+	linesPerBlock := 1
+	if f.attributes["compression"][0] == 3 {
+		linesPerBlock = exrLinesPerBlock
+	}
+
+	blocks := (height + linesPerBlock - 1) / linesPerBlock
+	offsets := make([]uint64, blocks)
+	if err := binary.Read(r, le, offsets); err != nil {
+		return nil, err
+	}
+	for i, offset := range offsets {
+		y, size := int(le.Uint32(data[offset:])), int(le.Uint32(data[offset+4:]))
+		if y != i*linesPerBlock {
+			return nil, fmt.Errorf("block %d starts at line %d", i, y)
+		}
+		lines := min(linesPerBlock, height-y)
+		block := data[offset+8 : int(offset)+8+size]
+		if raw := lines * width * len(f.channels) * 4; size < raw {
+			var err error
+			if block, err = exrZIPDecompress(block); err != nil {
+				return nil, err
+			}
+			f.compressed++
+		}
+		values := make([]float32, len(block)/4)
+		binary.Read(bytes.NewReader(block), le, values)
+		for line := range lines {
+			for c, name := range f.channels {
+				start := (line*len(f.channels) + c) * width
+				f.pixels[name] = append(f.pixels[name], values[start:start+width]...)
+			}
+		}
+	}
+	return f, nil
+}
+
+// exrZIPDecompress undoes exrZIPCompress.
+func exrZIPDecompress(data []byte) ([]byte, error) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(tmp); i++ {
+		tmp[i] = byte(int(tmp[i-1]) + int(tmp[i]) - 128)
+	}
+	result := make([]byte, len(tmp))
+	half := (len(tmp) + 1) / 2
+	for i := range result {
+		if i%2 == 0 {
+			result[i] = tmp[i/2]
+		} else {
+			result[i] = tmp[half+i/2]
+		}
+	}
+	return result, nil
+}
+
+func TestWriteEXR(t *testing.T) {
+	tests := []struct {
+		name        string
+		opaque      bool
+		compression exrCompression
+		channels    string
+	}{
+		{"opaque", true, exrNone, "B G R"},
+		{"alpha", false, exrNone, "A B G R"},
+		{"zip", true, exrZIP, "B G R"},
+		{"zip alpha", false, exrZIP, "A B G R"},
+	}
+	for _, test := range tests {
+		// 20 lines make a full and a partial ZIP block.
+		img := testImage(7, 20, test.opaque)
+		var b bytes.Buffer
+		if err := writeEXR(&b, img, test.compression); err != nil {
+			t.Fatal(err)
+		}
+		f, err := readEXR(b.Bytes())
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := strings.Join(f.channels, " "); got != test.channels {
+			t.Errorf("%s: channels %s, want %s", test.name, got, test.channels)
+		}
+		if got := f.attributes["compression"][0]; got != map[exrCompression]byte{exrNone: 0, exrZIP: 3}[test.compression] {
+			t.Errorf("%s: compression %d", test.name, got)
+		}
+		if test.compression == exrZIP && f.compressed == 0 {
+			t.Errorf("%s: no block was compressed", test.name)
+		}
+		if window := f.attributes["dataWindow"]; !bytes.Equal(window, f.attributes["displayWindow"]) || binary.LittleEndian.Uint32(window[8:]) != 6 || binary.LittleEndian.Uint32(window[12:]) != 19 {
+			t.Errorf("%s: data window % x, display window % x", test.name, window, f.attributes["displayWindow"])
+		}
+		for name, component := range map[string]int{"R": 0, "G": 1, "B": 2, "A": 3} {
+			pixels, ok := f.pixels[name]
+			if !ok {
+				continue
+			}
+			for i, c := range img.pix {
+				if math.Float32bits(pixels[i]) != math.Float32bits(c[component]) {
+					t.Errorf("%s: %s of pixel %d is %v, want %v", test.name, name, i, pixels[i], c[component])
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package equation
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"slices"
+)
+
+// Equal reports whether a and b are the same tree: the same operations and
+// constant values in the same places, up to the order of the operands of
+// Plus and Mult. 0 and -0 are the same constant. Settings of images are not
+// compared.
+func Equal(a, b BaseNode) bool {
+	if !sameLabel(a, b) {
+		return false
+	}
+	childrenA, childrenB := a.GetChildren(), b.GetChildren()
+	if len(childrenA) != len(childrenB) {
+		return false
+	}
+	same := true
+	for i := range childrenA {
+		if !Equal(childrenA[i], childrenB[i]) {
+			same = false
+			break
+		}
+	}
+	switch a.(type) {
+	case *OpPlus, *OpMult:
+		return same || Equal(childrenA[0], childrenB[1]) && Equal(childrenA[1], childrenB[0])
+	}
+	return same
+}
+
+// sameLabel reports whether a and b are the same operation, or constants of
+// the same value.
+func sameLabel(a, b BaseNode) bool {
+	constantA, okA := a.(*OpConstant)
+	constantB, okB := b.(*OpConstant)
+	if okA || okB {
+		return okA && okB && constantA.value == constantB.value
+	}
+	return Name(a) == Name(b)
+}
+
+// Hash returns a hash of the tree that does not change between runs. Equal
+// trees have the same hash, and so do trees that only differ in the order of
+// the operands of Plus and Mult. Settings of images are not hashed.
+func Hash(node BaseNode) uint64 {
+	children := node.GetChildren()
+	hashes := make([]uint64, len(children))
+	for i, child := range children {
+		hashes[i] = Hash(child)
+	}
+	switch node.(type) {
+	case *OpPlus, *OpMult:
+		slices.Sort(hashes)
+	}
+
+	h := fnv.New64a()
+	if c, ok := node.(*OpConstant); ok {
+		// 0 and -0 are the same constant.
+		value := c.value
+		if value == 0 {
+			value = 0
+		}
+		h.Write([]byte("Constant"))
+		binary.Write(h, binary.LittleEndian, math.Float32bits(value))
+	} else {
+		h.Write([]byte(Name(node)))
+	}
+	binary.Write(h, binary.LittleEndian, hashes)
+	return h.Sum64()
+}
+
+type ChangeKind int
+
+const (
+	// Inserted is a node added above the subtree at Path.
+	Inserted ChangeKind = iota
+	// Removed is a node at Path replaced by one of its children.
+	Removed
+	// Changed is a node at Path with a new operation or value. If the arity
+	// stayed the same its children are compared in turn, otherwise the
+	// whole subtree was replaced.
+	Changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Inserted:
+		return "inserted"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	}
+	return "unknown"
+}
+
+// Change is a difference between two trees. Path holds the indices of the
+// children followed from the root to the node. Old is the subtree at Path in
+// the first tree, New the one in the second.
+type Change struct {
+	Kind     ChangeKind
+	Path     []int
+	Old, New BaseNode
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case Inserted:
+		return fmt.Sprintf("inserted %s at %v", Name(c.New), c.Path)
+	case Removed:
+		return fmt.Sprintf("removed %s at %v", Name(c.Old), c.Path)
+	}
+	return fmt.Sprintf("changed %s to %s at %v", Name(c.Old), Name(c.New), c.Path)
+}
+
+// Diff returns the changes that turn a into b, in depth-first order. It
+// returns nil if the trees are equal.
+func Diff(a, b BaseNode) []Change {
+	var changes []Change
+	var diff func(a, b BaseNode, path []int)
+	diff = func(a, b BaseNode, path []int) {
+		if Equal(a, b) {
+			return
+		}
+		childrenA, childrenB := a.GetChildren(), b.GetChildren()
+		recurse := func() {
+			for i := range childrenA {
+				diff(childrenA[i], childrenB[i], append(slices.Clip(path), i))
+			}
+		}
+
+		if sameLabel(a, b) {
+			recurse()
+			return
+		}
+		for _, child := range childrenB {
+			if Equal(a, child) {
+				changes = append(changes, Change{Inserted, path, a, b})
+				return
+			}
+		}
+		for _, child := range childrenA {
+			if Equal(child, b) {
+				changes = append(changes, Change{Removed, path, a, b})
+				return
+			}
+		}
+		changes = append(changes, Change{Changed, path, a, b})
+		if len(childrenA) == len(childrenB) {
+			recurse()
+		}
+	}
+	diff(a, b, []int{})
+	return changes
+}
@@ -0,0 +1,74 @@
+package equation_test
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+)
+
+func TestEqualAndHash(t *testing.T) {
+	a := WithChildren(NewOpSin(), NewOpX())
+	b := WithChildren(NewOpDiv(), NewOpY(), NewOpConstant(2))
+	tests := []struct {
+		name string
+		x, y BaseNode
+		want bool
+	}{
+		{"plus commutes", WithChildren(NewOpPlus(), a, b), WithChildren(NewOpPlus(), CopyTree(b), CopyTree(a)), true},
+		{"mult commutes", WithChildren(NewOpMult(), a, b), WithChildren(NewOpMult(), CopyTree(b), CopyTree(a)), true},
+		{"minus does not", WithChildren(NewOpMinus(), a, b), WithChildren(NewOpMinus(), CopyTree(b), CopyTree(a)), false},
+		{"div does not", WithChildren(NewOpDiv(), a, b), WithChildren(NewOpDiv(), CopyTree(b), CopyTree(a)), false},
+		{"nested", WithChildren(NewOpCos(), WithChildren(NewOpMult(), NewOpX(), NewOpY())), WithChildren(NewOpCos(), WithChildren(NewOpMult(), NewOpY(), NewOpX())), true},
+		{"signed zero", NewOpConstant(0), NewOpConstant(float32(math.Copysign(0, -1))), true},
+		{"constants", NewOpConstant(1), NewOpConstant(2), false},
+		{"operations", WithChildren(NewOpSin(), NewOpX()), WithChildren(NewOpCos(), NewOpX()), false},
+	}
+	for _, test := range tests {
+		if got := Equal(test.x, test.y); got != test.want {
+			t.Errorf("%s: Equal(%s, %s) = %v, want %v", test.name, test.x, test.y, got, test.want)
+		}
+		if got := Equal(test.y, test.x); got != test.want {
+			t.Errorf("%s: Equal(%s, %s) = %v, want %v", test.name, test.y, test.x, got, test.want)
+		}
+		if got := Hash(test.x) == Hash(test.y); got != test.want {
+			t.Errorf("%s: hashes of %s and %s equal = %v, want %v", test.name, test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	plus := func(a, b BaseNode) BaseNode {
+		return WithChildren(NewOpPlus(), a, b)
+	}
+	sin := func(a BaseNode) BaseNode {
+		return WithChildren(NewOpSin(), a)
+	}
+	tests := []struct {
+		name string
+		a, b BaseNode
+		want []Change
+	}{
+		{"equal", plus(NewOpX(), NewOpY()), plus(NewOpY(), NewOpX()), nil},
+		{"inserted", plus(NewOpX(), NewOpY()), plus(sin(NewOpX()), NewOpY()), []Change{{Kind: Inserted, Path: []int{0}}}},
+		{"removed", plus(sin(NewOpX()), NewOpY()), plus(NewOpX(), NewOpY()), []Change{{Kind: Removed, Path: []int{0}}}},
+		{"changed", plus(NewOpX(), NewOpY()), plus(NewOpX(), NewOpConstant(2)), []Change{{Kind: Changed, Path: []int{1}}}},
+		{
+			"changed root and child",
+			plus(NewOpX(), NewOpY()),
+			WithChildren(NewOpMinus(), NewOpX(), NewOpConstant(2)),
+			[]Change{{Kind: Changed, Path: []int{}}, {Kind: Changed, Path: []int{1}}},
+		},
+	}
+	for _, test := range tests {
+		got := Diff(test.a, test.b)
+		same := len(got) == len(test.want)
+		for i := 0; same && i < len(got); i++ {
+			same = got[i].Kind == test.want[i].Kind && slices.Equal(got[i].Path, test.want[i].Path)
+		}
+		if !same {
+			t.Errorf("%s: Diff(%s, %s) = %v, want %v", test.name, test.a, test.b, got, test.want)
+		}
+	}
+}
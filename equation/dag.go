@@ -0,0 +1,233 @@
+package equation
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DAG holds one or more trees with every repeated subtree stored once, so a
+// node can have several parents. Evaluating it computes every distinct
+// subexpression once per point.
+type DAG struct {
+	nodes []dagNode
+	index map[dagNode]int
+	// Roots are the nodes of the trees added, in order.
+	Roots []int
+}
+
+// dagNode is an operation, given by its index in Ops, applied to earlier
+// nodes of the DAG. Constants are compared by their bits, so 0 and -0 stay
+// apart.
+type dagNode struct {
+	op       int
+	kind     dagKind
+	bits     uint32
+	children [2]int
+}
+
+// dagKind tells Eval what a node computes without looking up its name.
+type dagKind int
+
+const (
+	dagX dagKind = iota
+	dagY
+	dagConstant
+	dagPlus
+	dagMinus
+	dagMult
+	dagDiv
+	dagSin
+	dagCos
+	dagAtan
+	dagAtan2
+)
+
+func (n dagNode) value() float32 {
+	return math.Float32frombits(n.bits)
+}
+
+func NewDAG(trees ...BaseNode) *DAG {
+	d := &DAG{index: make(map[dagNode]int)}
+	for _, tree := range trees {
+		d.Add(tree)
+	}
+	return d
+}
+
+// Add adds tree as a new root, sharing the subtrees the DAG already has, and
+// returns its node.
+func (d *DAG) Add(tree BaseNode) int {
+	root := d.add(tree)
+	d.Roots = append(d.Roots, root)
+	return root
+}
+
+func (d *DAG) add(node BaseNode) int {
+	n := dagNode{op: OpIndex(node)}
+	if n.op < 0 {
+		panic("call add on " + Name(node))
+	}
+	switch node := node.(type) {
+	case *OpX:
+		n.kind = dagX
+	case *OpY:
+		n.kind = dagY
+	case *OpConstant:
+		n.kind = dagConstant
+		n.bits = math.Float32bits(node.value)
+	case *OpPlus:
+		n.kind = dagPlus
+	case *OpMinus:
+		n.kind = dagMinus
+	case *OpMult:
+		n.kind = dagMult
+	case *OpDiv:
+		n.kind = dagDiv
+	case *OpSin:
+		n.kind = dagSin
+	case *OpCos:
+		n.kind = dagCos
+	case *OpAtan:
+		n.kind = dagAtan
+	case *OpAtan2:
+		n.kind = dagAtan2
+	}
+	for i, child := range node.GetChildren() {
+		n.children[i] = d.add(child)
+	}
+	if id, ok := d.index[n]; ok {
+		return id
+	}
+	d.nodes = append(d.nodes, n)
+	d.index[n] = len(d.nodes) - 1
+	return len(d.nodes) - 1
+}
+
+// Len returns the number of distinct nodes.
+func (d *DAG) Len() int {
+	return len(d.nodes)
+}
+
+// Eval stores the value at x, y of every node in values, which must hold Len
+// values. The value of a root is values[d.Roots[i]]. Every node gives the
+// same value as in the tree it came from.
+func (d *DAG) Eval(x, y float32, values []float32) {
+	for i, n := range d.nodes {
+		a, b := values[n.children[0]], values[n.children[1]]
+		var v float32
+		switch n.kind {
+		case dagX:
+			v = x
+		case dagY:
+			v = y
+		case dagConstant:
+			v = n.value()
+		case dagPlus:
+			v = a + b
+		case dagMinus:
+			v = a - b
+		case dagMult:
+			v = a * b
+		case dagDiv:
+			v = a / b
+		case dagSin:
+			v = float32(math.Sin(float64(a)))
+		case dagCos:
+			v = float32(math.Cos(float64(a)))
+		case dagAtan:
+			v = float32(math.Atan(float64(a)))
+		case dagAtan2:
+			v = float32(math.Atan2(float64(a), float64(b)))
+		}
+		values[i] = v
+	}
+}
+
+// Tree expands the node id of the DAG into a new tree.
+func (d *DAG) Tree(id int) BaseNode {
+	n := d.nodes[id]
+	node := Ops[n.op].New()
+	if c, ok := node.(*OpConstant); ok {
+		c.value = n.value()
+	}
+	for i := range node.GetChildren() {
+		child := d.Tree(n.children[i])
+		node.GetChildren()[i] = child
+		child.SetParent(node)
+	}
+	return node
+}
+
+// SwapXY returns a copy of node with X and Y exchanged.
+func SwapXY(node BaseNode) BaseNode {
+	var result BaseNode
+	switch node.(type) {
+	case *OpX:
+		result = NewOpY()
+	case *OpY:
+		result = NewOpX()
+	default:
+		result = CopyNode(node)
+	}
+	for i, child := range node.GetChildren() {
+		result.GetChildren()[i] = SwapXY(child)
+		result.GetChildren()[i].SetParent(result)
+	}
+	return result
+}
+
+// Shared formats node like String, but every subtree of more than one node
+// that appears more than once is written once in a binding:
+//
+//	let s0 = Sin(X) in Plus(s0, s0)
+//
+// Bindings come in order, each can use the ones before it.
+func Shared(node BaseNode) string {
+	d := NewDAG(node)
+	uses := make([]int, d.Len())
+	var count func(id int)
+	count = func(id int) {
+		uses[id]++
+		if uses[id] > 1 {
+			return
+		}
+		n := d.nodes[id]
+		for i := range Ops[n.op].Arity {
+			count(n.children[i])
+		}
+	}
+	count(d.Roots[0])
+
+	names := make([]string, d.Len())
+	var format func(id int, binding bool) string
+	format = func(id int, binding bool) string {
+		if names[id] != "" && !binding {
+			return names[id]
+		}
+		n := d.nodes[id]
+		op := Ops[n.op]
+		switch op.Arity {
+		case 0:
+			if op.Name == "Constant" {
+				return NewOpConstant(n.value()).String()
+			}
+			return op.Name
+		case 1:
+			return fmt.Sprintf("%s(%s)", op.Name, format(n.children[0], false))
+		}
+		return fmt.Sprintf("%s(%s, %s)", op.Name, format(n.children[0], false), format(n.children[1], false))
+	}
+
+	var b strings.Builder
+	bindings := 0
+	for id, n := range d.nodes {
+		if uses[id] > 1 && Ops[n.op].Arity > 0 {
+			names[id] = fmt.Sprintf("s%d", bindings)
+			bindings++
+			fmt.Fprintf(&b, "let %s = %s in ", names[id], format(id, true))
+		}
+	}
+	b.WriteString(format(d.Roots[0], false))
+	return b.String()
+}
@@ -0,0 +1,35 @@
+package equation_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+func TestDAGEvalMatchesEval(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for range 300 {
+		// Repeat a subtree so the DAG shares nodes between and within trees.
+		shared := RandomTree(rng.Intn(6), rng)
+		trees := []BaseNode{
+			WithChildren(NewOpPlus(), shared, WithChildren(NewOpSin(), CopyTree(shared))),
+			RandomTree(rng.Intn(15), rng),
+			WithChildren(NewOpMult(), RandomTree(rng.Intn(6), rng), CopyTree(shared)),
+		}
+		d := NewDAG(trees...)
+		values := make([]float32, d.Len())
+		for range 10 {
+			x, y := rng.Float32()*4-2, rng.Float32()*4-2
+			d.Eval(x, y, values)
+			for i, tree := range trees {
+				got, want := values[d.Roots[i]], tree.Eval(x, y)
+				if math.Float32bits(got) != math.Float32bits(want) && !(got != got && want != want) {
+					t.Fatalf("%s at %v, %v: DAG gives %v, Eval %v", tree, x, y, got, want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package equation
+
+import (
+	"math"
+)
+
+// Variable is a coordinate a tree can be derived with respect to.
+type Variable int
+
+const (
+	VariableX Variable = iota
+	VariableY
+)
+
+func (v Variable) String() string {
+	switch v {
+	case VariableX:
+		return "X"
+	case VariableY:
+		return "Y"
+	}
+	return "unknown"
+}
+
+// Derive returns a new tree for the partial derivative of node with respect
+// to variable. node is left untouched.
+func Derive(node BaseNode, variable Variable) BaseNode {
+	return Simplify(derive(node, variable))
+}
+
+func derive(node BaseNode, variable Variable) BaseNode {
+	children := node.GetChildren()
+	// u and v return fresh copies of the operands, as every node can only
+	// have one parent.
+	u := func() BaseNode { return CopyTree(children[0]) }
+	v := func() BaseNode { return CopyTree(children[1]) }
+	du := func() BaseNode { return derive(children[0], variable) }
+	dv := func() BaseNode { return derive(children[1], variable) }
+
+	switch node.(type) {
+	case *OpX:
+		if variable == VariableX {
+			return NewOpConstant(1)
+		}
+		return NewOpConstant(0)
+	case *OpY:
+		if variable == VariableY {
+			return NewOpConstant(1)
+		}
+		return NewOpConstant(0)
+	case *OpConstant:
+		return NewOpConstant(0)
+	case *OpPlus:
+		return WithChildren(NewOpPlus(), du(), dv())
+	case *OpMinus:
+		return WithChildren(NewOpMinus(), du(), dv())
+	case *OpMult:
+		// u'v + uv'
+		return WithChildren(NewOpPlus(),
+			WithChildren(NewOpMult(), du(), v()),
+			WithChildren(NewOpMult(), u(), dv()))
+	case *OpDiv:
+		// (u'v - uv') / v²
+		return WithChildren(NewOpDiv(),
+			WithChildren(NewOpMinus(),
+				WithChildren(NewOpMult(), du(), v()),
+				WithChildren(NewOpMult(), u(), dv())),
+			WithChildren(NewOpMult(), v(), v()))
+	case *OpSin:
+		return WithChildren(NewOpMult(), WithChildren(NewOpCos(), u()), du())
+	case *OpCos:
+		return WithChildren(NewOpMult(),
+			NewOpConstant(-1),
+			WithChildren(NewOpMult(), WithChildren(NewOpSin(), u()), du()))
+	case *OpAtan:
+		// u' / (1 + u²)
+		return WithChildren(NewOpDiv(),
+			du(),
+			WithChildren(NewOpPlus(), NewOpConstant(1), WithChildren(NewOpMult(), u(), u())))
+	case *OpAtan2:
+		// Atan2(u, v) is the angle of the point (v, u), so its derivative
+		// is (u'v - uv') / (u² + v²) in every quadrant.
+		return WithChildren(NewOpDiv(),
+			WithChildren(NewOpMinus(),
+				WithChildren(NewOpMult(), du(), v()),
+				WithChildren(NewOpMult(), u(), dv())),
+			WithChildren(NewOpPlus(),
+				WithChildren(NewOpMult(), u(), u()),
+				WithChildren(NewOpMult(), v(), v())))
+	case *OpImage:
+		panic("call derive on image node")
+	}
+	panic("unknown node type")
+}
+
+// Simplify returns a copy of node with constant subtrees folded and the
+// identities of addition, subtraction, multiplication and division removed.
+// Multiplying by zero gives zero even where the other operand is not
+// finite.
+func Simplify(node BaseNode) BaseNode {
+	simplified := CopyNode(node)
+	_, image := node.(*OpImage)
+	constant := len(node.GetChildren()) > 0 && !image
+	for i, child := range node.GetChildren() {
+		simplified.GetChildren()[i] = Simplify(child)
+		simplified.GetChildren()[i].SetParent(simplified)
+		if _, ok := simplified.GetChildren()[i].(*OpConstant); !ok {
+			constant = false
+		}
+	}
+
+	if constant {
+		value := simplified.Eval(0, 0)
+		if !math.IsNaN(float64(value)) && !math.IsInf(float64(value), 0) {
+			return NewOpConstant(value)
+		}
+		return simplified
+	}
+
+	children := simplified.GetChildren()
+	is := func(i int, value float32) bool {
+		c, ok := children[i].(*OpConstant)
+		return ok && c.value == value
+	}
+	detach := func(i int) BaseNode {
+		children[i].SetParent(nil)
+		return children[i]
+	}
+	switch simplified.(type) {
+	case *OpPlus:
+		if is(0, 0) {
+			return detach(1)
+		}
+		if is(1, 0) {
+			return detach(0)
+		}
+	case *OpMinus:
+		if is(1, 0) {
+			return detach(0)
+		}
+	case *OpMult:
+		if is(0, 0) || is(1, 0) {
+			return NewOpConstant(0)
+		}
+		if is(0, 1) {
+			return detach(1)
+		}
+		if is(1, 1) {
+			return detach(0)
+		}
+	case *OpDiv:
+		if is(0, 0) {
+			return NewOpConstant(0)
+		}
+		if is(1, 1) {
+			return detach(0)
+		}
+	}
+	return simplified
+}
@@ -0,0 +1,76 @@
+package equation_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+// centralDifference estimates the derivative of node at x, y with respect
+// to variable with steps of h.
+func centralDifference(node BaseNode, variable Variable, x, y, h float32) float64 {
+	if variable == VariableX {
+		return float64(node.Eval(x+h, y)-node.Eval(x-h, y)) / float64(2*h)
+	}
+	return float64(node.Eval(x, y+h)-node.Eval(x, y-h)) / float64(2*h)
+}
+
+func finite(values ...float64) bool {
+	for _, v := range values {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// near reports whether a and b agree to a relative tolerance, or an
+// absolute one near zero.
+func near(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*max(1, math.Abs(a), math.Abs(b))
+}
+
+func TestDeriveMatchesFiniteDifferences(t *testing.T) {
+	const h = 1.0 / 256
+	rng := rand.New(rand.NewSource(1))
+	checked := 0
+	for range 1000 {
+		tree := RandomTree(rng.Intn(8), rng)
+		for _, variable := range []Variable{VariableX, VariableY} {
+			derivative := Derive(tree, variable)
+			for range 10 {
+				x, y := rng.Float32()*2-1, rng.Float32()*2-1
+				want := centralDifference(tree, variable, x, y, h/2)
+				// Near poles and jumps the estimate does not settle as the
+				// step shrinks, there it says nothing.
+				coarse := centralDifference(tree, variable, x, y, h)
+				if !finite(want, coarse) || !near(want, coarse, 1e-2) {
+					continue
+				}
+				got := float64(derivative.Eval(x, y))
+				if !near(got, want, 2e-2) {
+					t.Errorf("d/d%s %s at %v, %v = %v, finite differences give %v", variable, tree, x, y, got, want)
+				}
+				checked++
+			}
+		}
+	}
+	if checked < 10000 {
+		t.Errorf("only %d points were smooth enough to check", checked)
+	}
+}
+
+func TestDeriveLeavesTreeAlone(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for range 200 {
+		tree := RandomTree(rng.Intn(10), rng)
+		before := tree.String()
+		Derive(tree, VariableX)
+		if tree.String() != before {
+			t.Fatalf("Derive changed %s into %s", before, tree)
+		}
+	}
+}
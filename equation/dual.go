@@ -0,0 +1,131 @@
+package equation
+
+import (
+	"math"
+)
+
+// Dual is the value of a tree at a point together with its gradient. Grad[0]
+// and Grad[1] are the partial derivatives with respect to X and Y, Grad[2+i]
+// the one with respect to the value of the i-th constant returned by
+// Constants.
+type Dual struct {
+	Value float32
+	Grad  []float32
+}
+
+// Constants returns the constants of the tree in depth-first order, the
+// order of GetNthNode.
+func Constants(node BaseNode) []*OpConstant {
+	constants := make([]*OpConstant, 0)
+	var collect func(node BaseNode)
+	collect = func(node BaseNode) {
+		if c, ok := node.(*OpConstant); ok {
+			constants = append(constants, c)
+		}
+		for _, child := range node.GetChildren() {
+			collect(child)
+		}
+	}
+	collect(node)
+	return constants
+}
+
+// DualEvaluator computes the value and gradient of a tree in one pass using
+// forward-mode automatic differentiation. The tree must not change shape
+// while the evaluator is used, but the values of its constants may.
+type DualEvaluator struct {
+	root      BaseNode
+	constants map[*OpConstant]int
+	size      int
+}
+
+func NewDualEvaluator(node BaseNode) *DualEvaluator {
+	constants := Constants(node)
+	e := &DualEvaluator{root: node, constants: make(map[*OpConstant]int, len(constants)), size: 2 + len(constants)}
+	for i, c := range constants {
+		e.constants[c] = 2 + i
+	}
+	return e
+}
+
+// EvalDual evaluates node at x, y together with its gradient. Use a
+// DualEvaluator to evaluate the same tree at many points.
+func EvalDual(node BaseNode, x, y float32) Dual {
+	return NewDualEvaluator(node).Eval(x, y)
+}
+
+// Eval returns the value of the tree at x, y, equal to that of BaseNode.Eval,
+// and its gradient.
+func (e *DualEvaluator) Eval(x, y float32) Dual {
+	return e.eval(e.root, x, y)
+}
+
+func (e *DualEvaluator) eval(node BaseNode, x, y float32) Dual {
+	result := Dual{Grad: make([]float32, e.size)}
+	children := node.GetChildren()
+	switch n := node.(type) {
+	case *OpX:
+		result.Value = x
+		result.Grad[0] = 1
+		return result
+	case *OpY:
+		result.Value = y
+		result.Grad[1] = 1
+		return result
+	case *OpConstant:
+		result.Value = n.value
+		result.Grad[e.constants[n]] = 1
+		return result
+	case *OpImage:
+		panic("call eval on image node")
+	}
+
+	u := e.eval(children[0], x, y)
+	// chain sets the gradient to a*u' + b*v'.
+	chain := func(v Dual, a, b float32) {
+		for i := range result.Grad {
+			result.Grad[i] = a * u.Grad[i]
+			if b != 0 {
+				result.Grad[i] += b * v.Grad[i]
+			}
+		}
+	}
+
+	switch node.(type) {
+	case *OpSin:
+		result.Value = float32(math.Sin(float64(u.Value)))
+		chain(Dual{}, float32(math.Cos(float64(u.Value))), 0)
+		return result
+	case *OpCos:
+		result.Value = float32(math.Cos(float64(u.Value)))
+		chain(Dual{}, -float32(math.Sin(float64(u.Value))), 0)
+		return result
+	case *OpAtan:
+		result.Value = float32(math.Atan(float64(u.Value)))
+		chain(Dual{}, 1/(1+u.Value*u.Value), 0)
+		return result
+	}
+
+	v := e.eval(children[1], x, y)
+	switch node.(type) {
+	case *OpPlus:
+		result.Value = u.Value + v.Value
+		chain(v, 1, 1)
+	case *OpMinus:
+		result.Value = u.Value - v.Value
+		chain(v, 1, -1)
+	case *OpMult:
+		result.Value = u.Value * v.Value
+		chain(v, v.Value, u.Value)
+	case *OpDiv:
+		result.Value = u.Value / v.Value
+		chain(v, 1/v.Value, -u.Value/(v.Value*v.Value))
+	case *OpAtan2:
+		result.Value = float32(math.Atan2(float64(u.Value), float64(v.Value)))
+		r := u.Value*u.Value + v.Value*v.Value
+		chain(v, v.Value/r, -u.Value/r)
+	default:
+		panic("unknown node type")
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+package equation_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+func TestEvalDualMatchesEval(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for range 1000 {
+		tree := RandomTree(rng.Intn(15), rng)
+		for range 10 {
+			x, y := rng.Float32()*4-2, rng.Float32()*4-2
+			got, want := EvalDual(tree, x, y).Value, tree.Eval(x, y)
+			if math.Float32bits(got) != math.Float32bits(want) && !(got != got && want != want) {
+				t.Fatalf("%s at %v, %v: EvalDual gives %v, Eval %v", tree, x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestEvalDualGradientMatchesDerive(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	checked := 0
+	for range 1000 {
+		tree := RandomTree(rng.Intn(8), rng)
+		derivatives := []BaseNode{Derive(tree, VariableX), Derive(tree, VariableY)}
+		for range 10 {
+			x, y := rng.Float32()*2-1, rng.Float32()*2-1
+			dual := EvalDual(tree, x, y)
+			for i, derivative := range derivatives {
+				got, want := float64(dual.Grad[i]), float64(derivative.Eval(x, y))
+				// Simplify drops products with zero that are NaN at poles,
+				// so only finite derivatives are compared.
+				if !finite(got, want) {
+					continue
+				}
+				if !near(got, want, 1e-3) {
+					t.Errorf("%s at %v, %v: gradient %d is %v, Derive gives %v", tree, x, y, i, got, want)
+				}
+				checked++
+			}
+		}
+	}
+	if checked < 10000 {
+		t.Errorf("only %d finite derivatives were compared", checked)
+	}
+}
+
+// constantDifference estimates the derivative of node at x, y with respect
+// to the value of c with steps of h, and leaves c as it was. It also reports
+// whether node is nearly straight over the step.
+func constantDifference(node BaseNode, c *OpConstant, x, y, h float32) (float64, bool) {
+	value := c.Value()
+	defer c.SetValue(value)
+	center := node.Eval(x, y)
+	c.SetValue(value + h)
+	above := node.Eval(x, y)
+	c.SetValue(value - h)
+	below := node.Eval(x, y)
+	bend := math.Abs(float64(center) - float64(above+below)/2)
+	straight := bend <= 1e-2*math.Abs(float64(above-below))+1e-6*max(1, math.Abs(float64(center)))
+	return float64(above-below) / float64(2*h), straight
+}
+
+func TestEvalDualConstantGradientMatchesFiniteDifferences(t *testing.T) {
+	const h = 1.0 / 256
+	rng := rand.New(rand.NewSource(7))
+	checked := 0
+	for range 1000 {
+		tree := RandomTree(rng.Intn(8), rng)
+		constants := Constants(tree)
+		evaluator := NewDualEvaluator(tree)
+		for range 10 {
+			x, y := rng.Float32()*2-1, rng.Float32()*2-1
+			dual := evaluator.Eval(x, y)
+			for i, c := range constants {
+				// Near poles and where a constant is scaled by a huge factor
+				// the estimate says nothing. Gradients through infinite
+				// subexpressions are NaN even where the value is flat.
+				got := float64(dual.Grad[2+i])
+				want, straight := constantDifference(tree, c, x, y, h/2)
+				coarse, _ := constantDifference(tree, c, x, y, h)
+				if !finite(got, want, coarse) || !straight || !near(want, coarse, 1e-2) {
+					continue
+				}
+				if !near(got, want, 2e-2) {
+					t.Errorf("%s at %v, %v: gradient of constant %d is %v, finite differences give %v", tree, x, y, i, got, want)
+				}
+				checked++
+			}
+		}
+	}
+	if checked < 5000 {
+		t.Errorf("only %d points were smooth enough to check", checked)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"math/rand"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 type BaseNode interface {
@@ -14,11 +15,32 @@ type BaseNode interface {
 	SetParent(parent BaseNode)
 	GetChildren() []BaseNode
 	SetChildren(children []BaseNode)
-	AddRandomNode(node BaseNode)
+	AddRandomNode(node BaseNode, rng Rand)
 	AddLeafNode(leaf BaseNode) bool // true if successfully added
 	NodeCount() int
 }
 
+// Rand is the source of randomness used to build and mutate trees.
+// *rand.Rand implements it.
+type Rand interface {
+	Intn(n int) int
+	Float32() float32
+}
+
+// DefaultRand uses the math/rand top-level functions and is safe for
+// concurrent use.
+var DefaultRand Rand = defaultRand{}
+
+type defaultRand struct{}
+
+func (defaultRand) Intn(n int) int {
+	return rand.Intn(n)
+}
+
+func (defaultRand) Float32() float32 {
+	return rand.Float32()
+}
+
 // ANCHOR
 type Node struct {
 	Parent   BaseNode
@@ -49,13 +71,13 @@ func (node *Node) SetChildren(children []BaseNode) {
 	node.Children = children
 }
 
-func (node *Node) AddRandomNode(newNode BaseNode) {
-	index := rand.Intn(len(node.Children))
+func (node *Node) AddRandomNode(newNode BaseNode, rng Rand) {
+	index := rng.Intn(len(node.Children))
 	if node.Children[index] == nil {
 		node.Children[index] = newNode
 		newNode.SetParent(node)
 	} else {
-		node.Children[index].AddRandomNode(newNode)
+		node.Children[index].AddRandomNode(newNode, rng)
 	}
 }
 
@@ -84,7 +106,9 @@ func NewNode(size int) Node {
 	return Node{nil, make([]BaseNode, size)}
 }
 
-func CopyTree(node BaseNode) BaseNode {
+// CopyNode returns a detached copy of node with the same type and value but
+// with all children left empty.
+func CopyNode(node BaseNode) BaseNode {
 	if node == nil {
 		return nil
 	}
@@ -97,8 +121,17 @@ func CopyTree(node BaseNode) BaseNode {
 		newNode.(*OpConstant).value = n.value
 	}
 
-	newChildren := make([]BaseNode, len(node.GetChildren()))
-	newNode.SetChildren(newChildren)
+	newNode.SetChildren(make([]BaseNode, len(node.GetChildren())))
+	return newNode
+}
+
+func CopyTree(node BaseNode) BaseNode {
+	if node == nil {
+		return nil
+	}
+
+	newNode := CopyNode(node)
+	newChildren := newNode.GetChildren()
 	for i := range newChildren {
 		newChildren[i] = CopyTree(node.GetChildren()[i])
 		newChildren[i].SetParent(newNode)
@@ -106,6 +139,15 @@ func CopyTree(node BaseNode) BaseNode {
 	return newNode
 }
 
+// WithChildren sets the children of op, in order, and returns it.
+func WithChildren(op BaseNode, children ...BaseNode) BaseNode {
+	for i, child := range children {
+		op.GetChildren()[i] = child
+		child.SetParent(op)
+	}
+	return op
+}
+
 func ReplaceNode(old BaseNode, new BaseNode){
 	parent := old.GetParent()
 	if parent != nil {
@@ -147,16 +189,16 @@ func GetNthNode(tree BaseNode, n int) BaseNode {
 	return result
 }
 
-func Mutate(node BaseNode) BaseNode {
+func Mutate(node BaseNode, rng Rand) BaseNode {
 	var newNode BaseNode
 
 	opTypeCount := 8
 	leafTypeCount := 3
-	n := rand.Intn(opTypeCount + leafTypeCount)
+	n := rng.Intn(opTypeCount + leafTypeCount)
 	if n < 8 {
-		newNode = RandomOpNode()
+		newNode = RandomOpNode(rng)
 	} else {
-		newNode = RandomLeafNode()
+		newNode = RandomLeafNode(rng)
 	}
 
 	// Point ParentNode to NewNode
@@ -181,7 +223,7 @@ func Mutate(node BaseNode) BaseNode {
 	// Add leaf to children if they are empty
 	for i, child := range newNode.GetChildren() {
 		if child == nil {
-			leaf := RandomLeafNode()
+			leaf := RandomLeafNode(rng)
 			newNode.GetChildren()[i] = leaf
 			leaf.SetParent(newNode)
 		}
@@ -230,16 +272,30 @@ type OpConstant struct {
 	value float32
 }
 
-func NewOpConstant() *OpConstant {
-	return &OpConstant{NewNode(0), rand.Float32()*2 - 1}
+func NewOpConstant(value float32) *OpConstant {
+	return &OpConstant{NewNode(0), value}
 }
 
 func (op *OpConstant) Eval(x, y float32) float32 {
 	return op.value
 }
 
+func (op *OpConstant) Value() float32 {
+	return op.value
+}
+
+func (op *OpConstant) SetValue(value float32) {
+	op.value = value
+}
+
+// String writes the value with 9 decimals, or in the shortest form that reads
+// back as the same float32 if 9 decimals lose some of it.
 func (op *OpConstant) String() string {
-	return strconv.FormatFloat(float64(op.value), 'f', 9, 32)
+	s := strconv.FormatFloat(float64(op.value), 'f', 9, 32)
+	if v, err := strconv.ParseFloat(s, 32); err == nil && float32(v) != op.value && !math.IsNaN(v) {
+		return strconv.FormatFloat(float64(op.value), 'g', -1, 32)
+	}
+	return s
 }
 
 // ANCHOR
@@ -378,8 +434,54 @@ func (op *OpAtan2) String() string {
 	return "Atan2(" + op.Children[0].String() + ", " + op.Children[1].String() + ")"
 }
 
-func RandomOpNode() BaseNode {
-	n := rand.Intn(8)
+// Setting is a named list of arguments stored with an image, such as the
+// viewport it is rendered with. It is written as Name(arg, ...).
+type Setting struct {
+	Name string
+	Args []string
+}
+
+func (s Setting) String() string {
+	return s.Name + "(" + strings.Join(s.Args, ", ") + ")"
+}
+
+// ANCHOR
+type OpImage struct {
+	Node
+	Settings []Setting
+}
+
+func NewOpImage() *OpImage {
+	return &OpImage{Node: NewNode(3)}
+}
+
+func (op *OpImage) Eval(x, y float32) float32 {
+	panic("call eval on image node")
+}
+
+// Setting returns the setting called name.
+func (op *OpImage) Setting(name string) (Setting, bool) {
+	for _, s := range op.Settings {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Setting{}, false
+}
+
+func (op *OpImage) String() string {
+	lines := make([]string, 0, len(op.Settings)+len(op.Children))
+	for _, s := range op.Settings {
+		lines = append(lines, s.String())
+	}
+	for _, child := range op.Children {
+		lines = append(lines, Shared(child))
+	}
+	return "(EquationImage \n" + strings.Join(lines, "\n") + ")"
+}
+
+func RandomOpNode(rng Rand) BaseNode {
+	n := rng.Intn(8)
 	switch n {
 	case 0:
 		return NewOpPlus()
@@ -401,22 +503,22 @@ func RandomOpNode() BaseNode {
 	panic("get random node failed")
 }
 
-func RandomLeafNode() BaseNode {
-	n := rand.Intn(3)
+func RandomLeafNode(rng Rand) BaseNode {
+	n := rng.Intn(3)
 	switch n {
 	case 0:
 		return NewOpX()
 	case 1:
 		return NewOpY()
 	case 2:
-		return NewOpConstant()
+		return NewOpConstant(rng.Float32()*2 - 1)
 	}
 	panic("get random node failed")
 }
 
-func PickRandomNode(tree BaseNode) BaseNode {
+func PickRandomNode(tree BaseNode, rng Rand) BaseNode {
 	count := tree.NodeCount()
-	n := rand.Intn(count)
+	n := rng.Intn(count)
 	result := GetNthNode(tree, n)
 	return result
 }
@@ -0,0 +1,19 @@
+// Package equationtest provides helpers for testing code that works on
+// equation trees.
+package equationtest
+
+import "github.com/toantht/texturegen/equation"
+
+// RandomTree builds a tree of about size operations.
+func RandomTree(size int, rng equation.Rand) equation.BaseNode {
+	if size <= 0 {
+		return equation.RandomLeafNode(rng)
+	}
+	node := equation.RandomOpNode(rng)
+	for i := range node.GetChildren() {
+		child := RandomTree(rng.Intn(size), rng)
+		node.GetChildren()[i] = child
+		child.SetParent(node)
+	}
+	return node
+}
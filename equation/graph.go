@@ -0,0 +1,171 @@
+package equation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// GraphOptions control the DOT and SVG drawings of a tree. With Annotate,
+// every node also shows the range of its values over X × Y.
+type GraphOptions struct {
+	Annotate bool
+	X, Y     Interval
+}
+
+// Category groups operations for coloring: "variable", "constant",
+// "arithmetic", "trigonometric" or "image".
+func Category(node BaseNode) string {
+	switch node.(type) {
+	case *OpX, *OpY:
+		return "variable"
+	case *OpConstant:
+		return "constant"
+	case *OpPlus, *OpMinus, *OpMult, *OpDiv:
+		return "arithmetic"
+	case *OpSin, *OpCos, *OpAtan, *OpAtan2:
+		return "trigonometric"
+	case *OpImage:
+		return "image"
+	}
+	panic("unknown node type")
+}
+
+var categoryColors = map[string]string{
+	"variable":      "#a6d8f0",
+	"constant":      "#f4e3a1",
+	"arithmetic":    "#b8e0b0",
+	"trigonometric": "#f2b8c6",
+	"image":         "#d0d0d0",
+}
+
+// graphNode is a node of the drawing, numbered in depth-first order.
+type graphNode struct {
+	id       int
+	node     BaseNode
+	label    []string
+	parent   int
+	depth    int
+	children []int
+}
+
+// graphNodes lists the nodes of the tree with their labels.
+func graphNodes(root BaseNode, opts GraphOptions) []*graphNode {
+	// The ranges of all subtrees come from one pass over each channel.
+	intervals := make(map[BaseNode]Interval)
+	if opts.Annotate {
+		if _, ok := root.(*OpImage); ok {
+			for _, channel := range root.GetChildren() {
+				ranges(channel, opts.X, opts.Y, intervals)
+			}
+		} else {
+			ranges(root, opts.X, opts.Y, intervals)
+		}
+	}
+
+	nodes := make([]*graphNode, 0, root.NodeCount())
+	var visit func(node BaseNode, parent, depth int)
+	visit = func(node BaseNode, parent, depth int) {
+		g := &graphNode{id: len(nodes), node: node, label: []string{Name(node)}, parent: parent, depth: depth}
+		if interval, ok := intervals[node]; ok {
+			g.label = append(g.label, formatInterval(interval))
+		}
+		nodes = append(nodes, g)
+		if parent >= 0 {
+			nodes[parent].children = append(nodes[parent].children, g.id)
+		}
+		for _, child := range node.GetChildren() {
+			visit(child, g.id, depth+1)
+		}
+	}
+	visit(root, -1, 0)
+	return nodes
+}
+
+func formatInterval(i Interval) string {
+	s := fmt.Sprintf("[%.3g, %.3g]", i.Low, i.High)
+	if i.NaN {
+		s += " NaN"
+	}
+	return s
+}
+
+// DOT returns the tree as a Graphviz digraph.
+func DOT(root BaseNode, opts GraphOptions) string {
+	var b strings.Builder
+	b.WriteString("digraph equation {\n")
+	b.WriteString("  node [shape=box, style=\"rounded,filled\", fontname=monospace];\n")
+	nodes := graphNodes(root, opts)
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "  n%d [label=%q, fillcolor=%q];\n", n.id, strings.Join(n.label, "\n"), categoryColors[Category(n.node)])
+	}
+	for _, n := range nodes {
+		for _, child := range n.children {
+			fmt.Fprintf(&b, "  n%d -> n%d;\n", n.id, child)
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+const (
+	svgCharWidth  = 7
+	svgLineHeight = 14
+	svgPadding    = 6
+	svgGap        = 12
+	svgRowGap     = 28
+)
+
+// SVG draws the tree top down. Leaves are spread evenly and every other node
+// is centred over its children.
+func SVG(root BaseNode, opts GraphOptions) string {
+	nodes := graphNodes(root, opts)
+	boxWidth, boxHeight, depth := 0, 0, 0
+	for _, n := range nodes {
+		for _, line := range n.label {
+			boxWidth = max(boxWidth, len(line)*svgCharWidth+2*svgPadding)
+		}
+		boxHeight = max(boxHeight, len(n.label)*svgLineHeight+2*svgPadding)
+		depth = max(depth, n.depth)
+	}
+
+	centers := make([]float64, len(nodes))
+	leaves := 0
+	var place func(n *graphNode)
+	place = func(n *graphNode) {
+		if len(n.children) == 0 {
+			centers[n.id] = float64(leaves*(boxWidth+svgGap)) + float64(boxWidth)/2
+			leaves++
+			return
+		}
+		for _, child := range n.children {
+			place(nodes[child])
+		}
+		centers[n.id] = (centers[n.children[0]] + centers[n.children[len(n.children)-1]]) / 2
+	}
+	place(nodes[0])
+	top := func(n *graphNode) int {
+		return n.depth * (boxHeight + svgRowGap)
+	}
+
+	width := leaves*(boxWidth+svgGap) - svgGap
+	height := (depth+1)*(boxHeight+svgRowGap) - svgRowGap
+	var b strings.Builder
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	b.WriteString("<g stroke=\"#606060\">\n")
+	for _, n := range nodes {
+		for _, child := range n.children {
+			fmt.Fprintf(&b, "<line x1=\"%g\" y1=\"%d\" x2=\"%g\" y2=\"%d\"/>\n", centers[n.id], top(n)+boxHeight, centers[child], top(nodes[child]))
+		}
+	}
+	b.WriteString("</g>\n")
+	b.WriteString("<g font-family=\"monospace\" font-size=\"12\" text-anchor=\"middle\">\n")
+	for _, n := range nodes {
+		fmt.Fprintf(&b, "<rect x=\"%g\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"4\" fill=\"%s\" stroke=\"#606060\"/>\n",
+			centers[n.id]-float64(boxWidth)/2, top(n), boxWidth, boxHeight, categoryColors[Category(n.node)])
+		for i, line := range n.label {
+			fmt.Fprintf(&b, "<text x=\"%g\" y=\"%d\">%s</text>\n", centers[n.id], top(n)+svgPadding+(i+1)*svgLineHeight-3, line)
+		}
+	}
+	b.WriteString("</g>\n</svg>\n")
+	return b.String()
+}
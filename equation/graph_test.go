@@ -0,0 +1,91 @@
+package equation_test
+
+import (
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+)
+
+var graphOptions = GraphOptions{Annotate: true, X: Interval{Low: 0, High: 1}, Y: Interval{Low: 1, High: 2}}
+
+func graphTree() BaseNode {
+	return WithChildren(NewOpPlus(), WithChildren(NewOpSin(), NewOpX()), WithChildren(NewOpDiv(), NewOpConstant(2), NewOpY()))
+}
+
+func TestDOT(t *testing.T) {
+	tests := []struct {
+		name string
+		tree BaseNode
+		want string
+	}{
+		{"tree", graphTree(), `digraph equation {
+  node [shape=box, style="rounded,filled", fontname=monospace];
+  n0 [label="Plus\n[1, 2.84]", fillcolor="#b8e0b0"];
+  n1 [label="Sin\n[0, 0.841]", fillcolor="#f2b8c6"];
+  n2 [label="X\n[0, 1]", fillcolor="#a6d8f0"];
+  n3 [label="Div\n[1, 2]", fillcolor="#b8e0b0"];
+  n4 [label="2.000000000\n[2, 2]", fillcolor="#f4e3a1"];
+  n5 [label="Y\n[1, 2]", fillcolor="#a6d8f0"];
+  n0 -> n1;
+  n0 -> n3;
+  n1 -> n2;
+  n3 -> n4;
+  n3 -> n5;
+}
+`},
+		{"image", WithChildren(NewOpImage(), NewOpX(), NewOpConstant(2), WithChildren(NewOpCos(), NewOpY())), `digraph equation {
+  node [shape=box, style="rounded,filled", fontname=monospace];
+  n0 [label="EquationImage", fillcolor="#d0d0d0"];
+  n1 [label="X\n[0, 1]", fillcolor="#a6d8f0"];
+  n2 [label="2.000000000\n[2, 2]", fillcolor="#f4e3a1"];
+  n3 [label="Cos\n[-0.416, 0.54]", fillcolor="#f2b8c6"];
+  n4 [label="Y\n[1, 2]", fillcolor="#a6d8f0"];
+  n0 -> n1;
+  n0 -> n2;
+  n0 -> n3;
+  n3 -> n4;
+}
+`},
+	}
+	for _, test := range tests {
+		if got := DOT(test.tree, graphOptions); got != test.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSVG(t *testing.T) {
+	want := `<svg xmlns="http://www.w3.org/2000/svg" width="291" height="176" viewBox="0 0 291 176">
+<g stroke="#606060">
+<line x1="120.25" y1="40" x2="44.5" y2="68"/>
+<line x1="120.25" y1="40" x2="196" y2="68"/>
+<line x1="44.5" y1="108" x2="44.5" y2="136"/>
+<line x1="196" y1="108" x2="145.5" y2="136"/>
+<line x1="196" y1="108" x2="246.5" y2="136"/>
+</g>
+<g font-family="monospace" font-size="12" text-anchor="middle">
+<rect x="75.75" y="0" width="89" height="40" rx="4" fill="#b8e0b0" stroke="#606060"/>
+<text x="120.25" y="17">Plus</text>
+<text x="120.25" y="31">[1, 2.84]</text>
+<rect x="0" y="68" width="89" height="40" rx="4" fill="#f2b8c6" stroke="#606060"/>
+<text x="44.5" y="85">Sin</text>
+<text x="44.5" y="99">[0, 0.841]</text>
+<rect x="0" y="136" width="89" height="40" rx="4" fill="#a6d8f0" stroke="#606060"/>
+<text x="44.5" y="153">X</text>
+<text x="44.5" y="167">[0, 1]</text>
+<rect x="151.5" y="68" width="89" height="40" rx="4" fill="#b8e0b0" stroke="#606060"/>
+<text x="196" y="85">Div</text>
+<text x="196" y="99">[1, 2]</text>
+<rect x="101" y="136" width="89" height="40" rx="4" fill="#f4e3a1" stroke="#606060"/>
+<text x="145.5" y="153">2.000000000</text>
+<text x="145.5" y="167">[2, 2]</text>
+<rect x="202" y="136" width="89" height="40" rx="4" fill="#a6d8f0" stroke="#606060"/>
+<text x="246.5" y="153">Y</text>
+<text x="246.5" y="167">[1, 2]</text>
+</g>
+</svg>
+`
+	if got := SVG(graphTree(), graphOptions); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
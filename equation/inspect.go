@@ -0,0 +1,204 @@
+package equation
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Name returns the name of the operation of node as written in .eqt files,
+// or the value of a constant.
+func Name(node BaseNode) string {
+	switch n := node.(type) {
+	case *OpX:
+		return "X"
+	case *OpY:
+		return "Y"
+	case *OpConstant:
+		return n.String()
+	case *OpPlus:
+		return "Plus"
+	case *OpMinus:
+		return "Minus"
+	case *OpMult:
+		return "Mult"
+	case *OpDiv:
+		return "Div"
+	case *OpSin:
+		return "Sin"
+	case *OpCos:
+		return "Cos"
+	case *OpAtan:
+		return "Atan"
+	case *OpAtan2:
+		return "Atan2"
+	case *OpImage:
+		return "EquationImage"
+	}
+	panic("unknown node type")
+}
+
+// Depth returns the number of nodes on the longest path from node to a leaf.
+func Depth(node BaseNode) int {
+	depth := 0
+	for _, child := range node.GetChildren() {
+		depth = max(depth, Depth(child))
+	}
+	return depth + 1
+}
+
+type OpCount struct {
+	Name  string
+	Count int
+}
+
+// OpHistogram counts the operations in the tree, most frequent first.
+// Constants are counted together under "Constant".
+func OpHistogram(node BaseNode) []OpCount {
+	counts := map[string]int{}
+	var count func(node BaseNode)
+	count = func(node BaseNode) {
+		name := Name(node)
+		if _, ok := node.(*OpConstant); ok {
+			name = "Constant"
+		}
+		counts[name]++
+		for _, child := range node.GetChildren() {
+			count(child)
+		}
+	}
+	count(node)
+
+	histogram := make([]OpCount, 0, len(counts))
+	for name, n := range counts {
+		histogram = append(histogram, OpCount{name, n})
+	}
+	sort.Slice(histogram, func(i, j int) bool {
+		if histogram[i].Count != histogram[j].Count {
+			return histogram[i].Count > histogram[j].Count
+		}
+		return histogram[i].Name < histogram[j].Name
+	})
+	return histogram
+}
+
+// Pretty formats the tree over several lines. Subtrees that fit in width
+// characters stay on one line, the others have one child per line indented
+// by indent.
+func Pretty(node BaseNode, width int, indent string) string {
+	var b strings.Builder
+	var write func(node BaseNode, depth int)
+	write = func(node BaseNode, depth int) {
+		prefix := strings.Repeat(indent, depth)
+		line := node.String()
+		if len(node.GetChildren()) == 0 || len(prefix)+len(line) <= width {
+			b.WriteString(prefix + line)
+			return
+		}
+
+		b.WriteString(prefix + Name(node) + "(\n")
+		for i, child := range node.GetChildren() {
+			write(child, depth+1)
+			if i < len(node.GetChildren())-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(prefix + ")")
+	}
+	write(node, 0)
+	return b.String()
+}
+
+// Infix formats the tree in the infix syntax, with only the parentheses the
+// precedence of the operators needs. An image becomes one line per setting
+// and one assignment per channel.
+func Infix(node BaseNode) string {
+	if image, ok := node.(*OpImage); ok {
+		lines := make([]string, 0, len(image.Settings)+len(image.Children))
+		for _, s := range image.Settings {
+			lines = append(lines, s.String())
+		}
+		for i, child := range image.Children {
+			lines = append(lines, string("rgba"[i])+" = "+Infix(child))
+		}
+		return strings.Join(lines, "\n")
+	}
+	s, _ := infix(node)
+	return s
+}
+
+// Precedences of the infix syntax, from loosest to tightest.
+const (
+	precedenceSum = iota
+	precedenceProduct
+	precedenceUnary
+	precedenceAtom
+)
+
+// infix returns node in the infix syntax and its precedence.
+func infix(node BaseNode) (string, int) {
+	children := node.GetChildren()
+	// operand formats a child, in parentheses if it binds looser than
+	// precedence.
+	operand := func(i int, precedence int) string {
+		s, p := infix(children[i])
+		if p < precedence {
+			return "(" + s + ")"
+		}
+		return s
+	}
+	binary := func(op string, precedence int) (string, int) {
+		// Operators group to the left, so a right operand of the same
+		// precedence keeps its parentheses to give back the same tree.
+		return operand(0, precedence) + " " + op + " " + operand(1, precedence+1), precedence
+	}
+
+	switch n := node.(type) {
+	case *OpX:
+		return "x", precedenceAtom
+	case *OpY:
+		return "y", precedenceAtom
+	case *OpConstant:
+		v := float64(n.value)
+		var s string
+		switch {
+		case math.IsNaN(v):
+			s = "nan"
+		case math.IsInf(v, 1):
+			s = "inf"
+		case math.IsInf(v, -1):
+			s = "-inf"
+		default:
+			s = strconv.FormatFloat(v, 'g', -1, 32)
+		}
+		if math.Signbit(v) {
+			return s, precedenceUnary
+		}
+		return s, precedenceAtom
+	case *OpPlus:
+		return binary("+", precedenceSum)
+	case *OpMinus:
+		if c, ok := children[0].(*OpConstant); ok && c.value == 0 && !math.Signbit(float64(c.value)) {
+			// A minus sign directly before a number is read as part of
+			// it, so a constant operand keeps its parentheses.
+			if _, ok := children[1].(*OpConstant); ok {
+				s, _ := infix(children[1])
+				return "-(" + s + ")", precedenceUnary
+			}
+			return "-" + operand(1, precedenceUnary), precedenceUnary
+		}
+		return binary("-", precedenceSum)
+	case *OpMult:
+		return binary("*", precedenceProduct)
+	case *OpDiv:
+		return binary("/", precedenceProduct)
+	}
+
+	args := make([]string, len(children))
+	for i, child := range children {
+		args[i], _ = infix(child)
+	}
+	return strings.ToLower(Name(node)) + "(" + strings.Join(args, ", ") + ")", precedenceAtom
+}
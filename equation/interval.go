@@ -0,0 +1,159 @@
+package equation
+
+import (
+	"math"
+)
+
+// Interval is a range of values, bounds included. Either bound can be
+// infinite.
+type Interval struct {
+	Low, High float32
+	// NaN is true if a value can also be NaN, for example after dividing
+	// zero by zero.
+	NaN bool
+}
+
+// Point returns the interval holding only v.
+func Point(v float32) Interval {
+	return Interval{Low: v, High: v}
+}
+
+func (i Interval) Width() float32 {
+	return i.High - i.Low
+}
+
+func (i Interval) Contains(v float32) bool {
+	return i.Low <= v && v <= i.High
+}
+
+// finite reports whether both bounds are finite.
+func (i Interval) finite() bool {
+	return !math.IsInf(float64(i.Low), 0) && !math.IsInf(float64(i.High), 0)
+}
+
+var everything = Interval{Low: float32(math.Inf(-1)), High: float32(math.Inf(1))}
+
+// span returns the smallest interval holding every value. NaN values only
+// set the NaN flag.
+func span(values ...float32) Interval {
+	result := Interval{Low: float32(math.Inf(1)), High: float32(math.Inf(-1))}
+	for _, v := range values {
+		if math.IsNaN(float64(v)) {
+			result.NaN = true
+			continue
+		}
+		result.Low = min(result.Low, v)
+		result.High = max(result.High, v)
+	}
+	if result.Low > result.High {
+		return Interval{everything.Low, everything.High, true}
+	}
+	return result
+}
+
+// Range bounds the values of node over the rectangle spanned by x and y
+// using interval arithmetic. The bounds are safe but not always tight, as
+// every occurrence of a variable is treated independently.
+func Range(node BaseNode, x, y Interval) Interval {
+	return ranges(node, x, y, nil)
+}
+
+// ranges computes Range and, if record is not nil, stores the range of every
+// subtree of node in it on the way.
+func ranges(node BaseNode, x, y Interval, record map[BaseNode]Interval) Interval {
+	children := node.GetChildren()
+	var result Interval
+	switch n := node.(type) {
+	case *OpX:
+		result = x
+	case *OpY:
+		result = y
+	case *OpConstant:
+		result = Point(n.value)
+	case *OpImage:
+		panic("call range on image node")
+	case *OpSin, *OpCos, *OpAtan:
+		u := ranges(children[0], x, y, record)
+		switch node.(type) {
+		case *OpSin:
+			result = periodic(u, math.Sin, math.Pi/2)
+		case *OpCos:
+			result = periodic(u, math.Cos, 0)
+		case *OpAtan:
+			result = span(float32(math.Atan(float64(u.Low))), float32(math.Atan(float64(u.High))))
+		}
+		result.NaN = result.NaN || u.NaN
+	default:
+		u := ranges(children[0], x, y, record)
+		v := ranges(children[1], x, y, record)
+		result = binaryRange(node, u, v)
+		result.NaN = result.NaN || u.NaN || v.NaN
+	}
+	if record != nil {
+		record[node] = result
+	}
+	return result
+}
+
+func binaryRange(node BaseNode, u, v Interval) Interval {
+	inf := float32(math.Inf(1))
+	var result Interval
+	switch node.(type) {
+	case *OpPlus:
+		// Infinities of opposite signs add up to NaN.
+		result = span(u.Low+v.Low, u.High+v.High)
+		result.NaN = result.NaN || u.Contains(inf) && v.Contains(-inf) || u.Contains(-inf) && v.Contains(inf)
+		return result
+	case *OpMinus:
+		result = span(u.Low-v.High, u.High-v.Low)
+		result.NaN = result.NaN || u.Contains(inf) && v.Contains(inf) || u.Contains(-inf) && v.Contains(-inf)
+		return result
+	case *OpMult:
+		// Zero times an infinity is NaN, even when neither is a bound.
+		result = span(u.Low*v.Low, u.Low*v.High, u.High*v.Low, u.High*v.High)
+		result.NaN = result.NaN || u.Contains(0) && !v.finite() || v.Contains(0) && !u.finite()
+		return result
+	case *OpDiv:
+		if v.Contains(0) {
+			// 0/0 and ±Inf/±Inf are NaN, anything else over an interval
+			// around zero can be as large as it likes.
+			nan := u.Contains(0) || (!u.finite() && !v.finite())
+			return Interval{everything.Low, everything.High, nan}
+		}
+		return span(u.Low/v.Low, u.Low/v.High, u.High/v.Low, u.High/v.High)
+	case *OpAtan2:
+		// The angle jumps from π to -π across the negative half of the x
+		// axis, and takes every value around the origin.
+		if u.Contains(0) && v.Low <= 0 {
+			return Interval{Low: -math.Pi, High: math.Pi}
+		}
+		// Otherwise the rectangle sees its extreme angles at its corners.
+		atan2 := func(a, b float32) float32 {
+			return float32(math.Atan2(float64(a), float64(b)))
+		}
+		return span(atan2(u.Low, v.Low), atan2(u.Low, v.High), atan2(u.High, v.Low), atan2(u.High, v.High))
+	}
+	panic("unknown node type")
+}
+
+// periodic bounds f, a sine wave with period 2π and a maximum at peak, over
+// u. Infinite inputs make f NaN.
+func periodic(u Interval, f func(float64) float64, peak float64) Interval {
+	if !u.finite() {
+		return Interval{Low: -1, High: 1, NaN: true}
+	}
+	if u.Width() >= 2*math.Pi {
+		return Interval{Low: -1, High: 1}
+	}
+	result := span(float32(f(float64(u.Low))), float32(f(float64(u.High))))
+	// The first maximum and minimum at or after the low bound.
+	maximum := peak + 2*math.Pi*math.Ceil((float64(u.Low)-peak)/(2*math.Pi))
+	minimum := peak + math.Pi + 2*math.Pi*math.Ceil((float64(u.Low)-peak-math.Pi)/(2*math.Pi))
+	if maximum <= float64(u.High) {
+		result.High = 1
+	}
+	if minimum <= float64(u.High) {
+		result.Low = -1
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package equation_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+func TestRangeFlagsOppositeInfinities(t *testing.T) {
+	inverse := WithChildren(NewOpDiv(), NewOpConstant(1), NewOpX())
+	tests := []BaseNode{
+		WithChildren(NewOpPlus(), inverse, WithChildren(NewOpMinus(), NewOpConstant(0), CopyTree(inverse))),
+		WithChildren(NewOpMinus(), inverse, CopyTree(inverse)),
+		WithChildren(NewOpMult(), NewOpY(), inverse),
+	}
+	x := Interval{Low: -1, High: 1}
+	for _, tree := range tests {
+		if v := tree.Eval(0, 0); !math.IsNaN(float64(v)) {
+			t.Fatalf("%s at 0, 0 is %v, want NaN", tree, v)
+		}
+		if r := Range(tree, x, x); !r.NaN {
+			t.Errorf("Range(%s) = %+v, want NaN", tree, r)
+		}
+	}
+}
+
+func TestRangeHoldsEveryValue(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for range 2000 {
+		tree := RandomTree(rng.Intn(12), rng)
+		x0, y0 := rng.Float32()*4-2, rng.Float32()*4-2
+		x := Interval{Low: x0, High: x0 + rng.Float32()}
+		y := Interval{Low: y0, High: y0 + rng.Float32()}
+		r := Range(tree, x, y)
+		for range 50 {
+			px, py := x.Low+rng.Float32()*x.Width(), y.Low+rng.Float32()*y.Width()
+			v := tree.Eval(px, py)
+			if math.IsNaN(float64(v)) && !r.NaN || !math.IsNaN(float64(v)) && !r.Contains(v) {
+				t.Fatalf("%s at %v, %v is %v, outside %+v", tree, px, py, v, r)
+			}
+		}
+	}
+}
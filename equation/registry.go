@@ -0,0 +1,38 @@
+package equation
+
+// Op describes an operation that can be created by name.
+type Op struct {
+	Name  string
+	Arity int
+	New   func() BaseNode
+}
+
+// Ops lists every node type that can appear in a channel tree. Constant
+// creates a constant of value 0.
+var Ops = []Op{
+	{"X", 0, func() BaseNode { return NewOpX() }},
+	{"Y", 0, func() BaseNode { return NewOpY() }},
+	{"Constant", 0, func() BaseNode { return NewOpConstant(0) }},
+	{"Plus", 2, func() BaseNode { return NewOpPlus() }},
+	{"Minus", 2, func() BaseNode { return NewOpMinus() }},
+	{"Mult", 2, func() BaseNode { return NewOpMult() }},
+	{"Div", 2, func() BaseNode { return NewOpDiv() }},
+	{"Sin", 1, func() BaseNode { return NewOpSin() }},
+	{"Cos", 1, func() BaseNode { return NewOpCos() }},
+	{"Atan", 1, func() BaseNode { return NewOpAtan() }},
+	{"Atan2", 2, func() BaseNode { return NewOpAtan2() }},
+}
+
+// OpIndex returns the index in Ops of the operation of node, or -1.
+func OpIndex(node BaseNode) int {
+	name := "Constant"
+	if _, ok := node.(*OpConstant); !ok {
+		name = Name(node)
+	}
+	for i, op := range Ops {
+		if op.Name == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Button calls OnClick when it is clicked, or when Enter is pressed while it
+// is focused.
+type Button struct {
+	base
+	focus
+	Label   string
+	OnClick func()
+	pressed bool
+}
+
+func NewButton(label string, onClick func()) *Button {
+	return &Button{Label: label, OnClick: onClick}
+}
+
+func (b *Button) PreferredSize() (int, int) {
+	return textWidth(b.Label) + 16, 20
+}
+
+func (b *Button) Update(input Input) {
+	hovered := b.updateHover(input)
+	if input.IsMouseButtonJustPressed(ebiten.MouseButton0) && hovered {
+		b.pressed = true
+	}
+	if input.IsMouseButtonJustReleased(ebiten.MouseButton0) {
+		if b.pressed && hovered {
+			b.click()
+		}
+		b.pressed = false
+	}
+	if b.focused && input.IsKeyJustPressed(ebiten.KeyEnter) {
+		b.click()
+	}
+}
+
+func (b *Button) click() {
+	if b.OnClick != nil {
+		b.OnClick()
+	}
+}
+
+func (b *Button) Draw(screen *ebiten.Image) {
+	key := fmt.Sprint(b.Label, b.hover, b.pressed, b.focused)
+	b.drawCached(screen, key, func(dst *ebiten.Image) {
+		c := backgroundColor
+		if b.pressed {
+			c = pressedColor
+		} else if b.hover {
+			c = hoverColor
+		}
+		frame(dst, c, b.focused)
+		drawCenteredText(dst, b.Label)
+	})
+}
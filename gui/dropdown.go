@@ -0,0 +1,129 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const dropdownItemHeight = 18
+
+// Dropdown shows the selected option and opens a list of every option when
+// clicked. OpenUp opens the list above the widget instead of below.
+type Dropdown struct {
+	base
+	focus
+	Options   []string
+	Selected  int
+	OpenUp    bool
+	OnChange  func(selected int)
+	open      bool
+	highlight int
+	list      base
+}
+
+func NewDropdown(options []string, selected int, onChange func(selected int)) *Dropdown {
+	return &Dropdown{Options: options, Selected: selected, OnChange: onChange}
+}
+
+func (d *Dropdown) PreferredSize() (int, int) {
+	width := 0
+	for _, option := range d.Options {
+		width = max(width, textWidth(option))
+	}
+	return width + 24, 20
+}
+
+func (d *Dropdown) listBounds() image.Rectangle {
+	height := len(d.Options) * dropdownItemHeight
+	if d.OpenUp {
+		return image.Rect(d.bounds.Min.X, d.bounds.Min.Y-height, d.bounds.Max.X, d.bounds.Min.Y)
+	}
+	return image.Rect(d.bounds.Min.X, d.bounds.Max.Y, d.bounds.Max.X, d.bounds.Max.Y+height)
+}
+
+func (d *Dropdown) Update(input Input) {
+	d.list.SetBounds(d.listBounds())
+	d.updateHover(input)
+
+	if !d.open {
+		clicked := input.IsMouseButtonJustPressed(ebiten.MouseButton0) && d.hover
+		if clicked || d.focused && input.IsKeyJustPressed(ebiten.KeyEnter) {
+			d.open = true
+			d.highlight = d.Selected
+		}
+		return
+	}
+
+	if d.list.updateHover(input) {
+		_, y := input.CursorPosition()
+		d.highlight = (y - d.list.bounds.Min.Y) / dropdownItemHeight
+	}
+	if input.IsKeyJustPressed(ebiten.KeyArrowUp) {
+		d.highlight = (d.highlight + len(d.Options) - 1) % len(d.Options)
+	}
+	if input.IsKeyJustPressed(ebiten.KeyArrowDown) {
+		d.highlight = (d.highlight + 1) % len(d.Options)
+	}
+
+	if input.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+		if d.list.hover {
+			d.selectOption(d.highlight)
+		}
+		d.open = false
+	}
+	if input.IsKeyJustPressed(ebiten.KeyEnter) {
+		d.selectOption(d.highlight)
+		d.open = false
+	}
+}
+
+func (d *Dropdown) selectOption(index int) {
+	if index < 0 || index >= len(d.Options) || index == d.Selected {
+		return
+	}
+	d.Selected = index
+	if d.OnChange != nil {
+		d.OnChange(index)
+	}
+}
+
+func (d *Dropdown) OverlayActive() bool {
+	return d.open
+}
+
+func (d *Dropdown) Draw(screen *ebiten.Image) {
+	label := d.Options[d.Selected]
+	key := fmt.Sprint(label, d.hover, d.open, d.focused)
+	d.drawCached(screen, key, func(dst *ebiten.Image) {
+		c := backgroundColor
+		if d.open {
+			c = pressedColor
+		} else if d.hover {
+			c = hoverColor
+		}
+		frame(dst, c, d.focused)
+		drawText(dst, label, 4)
+
+		arrow := "v"
+		if d.OpenUp {
+			arrow = "^"
+		}
+		drawText(dst, arrow, dst.Bounds().Dx()-charWidth-4)
+	})
+}
+
+func (d *Dropdown) DrawOverlay(screen *ebiten.Image) {
+	key := fmt.Sprint(d.highlight, d.Options)
+	d.list.drawCached(screen, key, func(dst *ebiten.Image) {
+		dst.Fill(backgroundColor)
+		for i, option := range d.Options {
+			item := dst.SubImage(image.Rect(0, i*dropdownItemHeight, dst.Bounds().Dx(), (i+1)*dropdownItemHeight)).(*ebiten.Image)
+			if i == d.highlight {
+				item.Fill(hoverColor)
+			}
+			drawText(item, option, 4)
+		}
+	})
+}
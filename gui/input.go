@@ -0,0 +1,178 @@
+package gui
+
+import (
+	"slices"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/inpututil"
+)
+
+// Input is the mouse and keyboard state of the current tick. Widgets and the
+// game read input only through it, so they can be driven by scripted events.
+type Input interface {
+	CursorPosition() (int, int)
+	IsMouseButtonPressed(button ebiten.MouseButton) bool
+	IsMouseButtonJustPressed(button ebiten.MouseButton) bool
+	IsMouseButtonJustReleased(button ebiten.MouseButton) bool
+	IsKeyPressed(key ebiten.Key) bool
+	IsKeyJustPressed(key ebiten.Key) bool
+	// KeyPressDuration is the number of ticks key has been held, or 0.
+	KeyPressDuration(key ebiten.Key) int
+	// Wheel returns the scroll offsets of the mouse wheel.
+	Wheel() (float64, float64)
+	AppendInputChars(runes []rune) []rune
+}
+
+// EbitenInput reads the real input through ebiten.
+type EbitenInput struct{}
+
+func (EbitenInput) CursorPosition() (int, int) {
+	return ebiten.CursorPosition()
+}
+
+func (EbitenInput) IsMouseButtonPressed(button ebiten.MouseButton) bool {
+	return ebiten.IsMouseButtonPressed(button)
+}
+
+func (EbitenInput) IsMouseButtonJustPressed(button ebiten.MouseButton) bool {
+	return inpututil.IsMouseButtonJustPressed(button)
+}
+
+func (EbitenInput) IsMouseButtonJustReleased(button ebiten.MouseButton) bool {
+	return inpututil.IsMouseButtonJustReleased(button)
+}
+
+func (EbitenInput) IsKeyPressed(key ebiten.Key) bool {
+	return ebiten.IsKeyPressed(key)
+}
+
+func (EbitenInput) IsKeyJustPressed(key ebiten.Key) bool {
+	return inpututil.IsKeyJustPressed(key)
+}
+
+func (EbitenInput) KeyPressDuration(key ebiten.Key) int {
+	return inpututil.KeyPressDuration(key)
+}
+
+func (EbitenInput) Wheel() (float64, float64) {
+	return ebiten.Wheel()
+}
+
+func (EbitenInput) AppendInputChars(runes []rune) []rune {
+	return ebiten.AppendInputChars(runes)
+}
+
+// InputFrame is the input of one tick. Buttons and keys listed as pressed
+// are held down; the just pressed and just released ones changed this tick.
+type InputFrame struct {
+	X, Y                int
+	Buttons             []ebiten.MouseButton
+	JustPressedButtons  []ebiten.MouseButton
+	JustReleasedButtons []ebiten.MouseButton
+	Keys                []ebiten.Key
+	JustPressedKeys     []ebiten.Key
+	WheelX, WheelY      float64
+	Chars               []rune
+}
+
+// ScriptedInput replays a list of frames, one per call to Step. Once the
+// script is exhausted the cursor stays put and nothing is pressed.
+type ScriptedInput struct {
+	frames   []InputFrame
+	current  InputFrame
+	held     map[ebiten.Key]int
+	position int
+}
+
+func NewScriptedInput(frames ...InputFrame) *ScriptedInput {
+	return &ScriptedInput{frames: frames, held: map[ebiten.Key]int{}}
+}
+
+// Push appends frames to the script.
+func (s *ScriptedInput) Push(frames ...InputFrame) {
+	s.frames = append(s.frames, frames...)
+}
+
+// Step makes the next frame current. It reports false once the script is
+// exhausted.
+func (s *ScriptedInput) Step() bool {
+	if s.position >= len(s.frames) {
+		s.current = InputFrame{X: s.current.X, Y: s.current.Y}
+		clear(s.held)
+		return false
+	}
+	s.current = s.frames[s.position]
+	s.position++
+
+	for key := range s.held {
+		if !slices.Contains(s.current.Keys, key) {
+			delete(s.held, key)
+		}
+	}
+	for _, key := range s.current.Keys {
+		s.held[key]++
+	}
+	for _, key := range s.current.JustPressedKeys {
+		s.held[key] = max(s.held[key], 1)
+	}
+	return true
+}
+
+func (s *ScriptedInput) CursorPosition() (int, int) {
+	return s.current.X, s.current.Y
+}
+
+func (s *ScriptedInput) IsMouseButtonPressed(button ebiten.MouseButton) bool {
+	return slices.Contains(s.current.Buttons, button) || slices.Contains(s.current.JustPressedButtons, button)
+}
+
+func (s *ScriptedInput) IsMouseButtonJustPressed(button ebiten.MouseButton) bool {
+	return slices.Contains(s.current.JustPressedButtons, button)
+}
+
+func (s *ScriptedInput) IsMouseButtonJustReleased(button ebiten.MouseButton) bool {
+	return slices.Contains(s.current.JustReleasedButtons, button)
+}
+
+func (s *ScriptedInput) IsKeyPressed(key ebiten.Key) bool {
+	return slices.Contains(s.current.Keys, key) || slices.Contains(s.current.JustPressedKeys, key)
+}
+
+func (s *ScriptedInput) IsKeyJustPressed(key ebiten.Key) bool {
+	return slices.Contains(s.current.JustPressedKeys, key)
+}
+
+func (s *ScriptedInput) KeyPressDuration(key ebiten.Key) int {
+	return s.held[key]
+}
+
+func (s *ScriptedInput) Wheel() (float64, float64) {
+	return s.current.WheelX, s.current.WheelY
+}
+
+func (s *ScriptedInput) AppendInputChars(runes []rune) []rune {
+	return append(runes, s.current.Chars...)
+}
+
+// cursor returns the cursor position of the last frame in the script.
+func (s *ScriptedInput) cursor() (int, int) {
+	if len(s.frames) > 0 {
+		last := s.frames[len(s.frames)-1]
+		return last.X, last.Y
+	}
+	return s.current.X, s.current.Y
+}
+
+// Click appends the press and release of button at x, y.
+func (s *ScriptedInput) Click(button ebiten.MouseButton, x, y int) {
+	s.Push(
+		InputFrame{X: x, Y: y, JustPressedButtons: []ebiten.MouseButton{button}},
+		InputFrame{X: x, Y: y, JustReleasedButtons: []ebiten.MouseButton{button}},
+	)
+}
+
+// PressKey appends a press of key at the current cursor position.
+func (s *ScriptedInput) PressKey(key ebiten.Key) {
+	x, y := s.cursor()
+	s.Push(InputFrame{X: x, Y: y, JustPressedKeys: []ebiten.Key{key}})
+}
@@ -0,0 +1,25 @@
+package gui
+
+import "github.com/hajimehoshi/ebiten/v2"
+
+// Label draws a line of text.
+type Label struct {
+	base
+	Text string
+}
+
+func NewLabel(text string) *Label {
+	return &Label{Text: text}
+}
+
+func (l *Label) PreferredSize() (int, int) {
+	return textWidth(l.Text), charHeight
+}
+
+func (l *Label) Update(input Input) {}
+
+func (l *Label) Draw(screen *ebiten.Image) {
+	l.drawCached(screen, l.Text, func(dst *ebiten.Image) {
+		drawText(dst, l.Text, 0)
+	})
+}
@@ -0,0 +1,114 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+const listItemHeight = 16
+
+// List shows lines of text and lets one be selected by clicking or with the
+// arrow keys. Clicking the selected line or pressing Enter calls OnToggle.
+type List struct {
+	base
+	focus
+	Items         []string
+	Selected      int
+	Width, Height int
+	OnSelect      func(index int)
+	OnToggle      func(index int)
+	scroll        int
+}
+
+func NewList(items []string, onSelect func(index int), onToggle func(index int)) *List {
+	return &List{Items: items, Width: 200, Height: 100, OnSelect: onSelect, OnToggle: onToggle}
+}
+
+func (l *List) PreferredSize() (int, int) {
+	return l.Width, l.Height
+}
+
+func (l *List) visibleItems() int {
+	return max(l.bounds.Dy()/listItemHeight, 1)
+}
+
+func (l *List) Update(input Input) {
+	if l.updateHover(input) {
+		if input.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+			_, y := input.CursorPosition()
+			index := l.scroll + (y-l.bounds.Min.Y)/listItemHeight
+			if index == l.Selected {
+				l.toggle()
+			} else {
+				l.Select(index)
+			}
+		}
+		_, wheel := input.Wheel()
+		if wheel > 0 {
+			l.scroll--
+		} else if wheel < 0 {
+			l.scroll++
+		}
+	}
+
+	if l.focused {
+		if input.IsKeyJustPressed(ebiten.KeyArrowUp) {
+			l.Select(l.Selected - 1)
+			l.reveal()
+		}
+		if input.IsKeyJustPressed(ebiten.KeyArrowDown) {
+			l.Select(l.Selected + 1)
+			l.reveal()
+		}
+		if input.IsKeyJustPressed(ebiten.KeyEnter) {
+			l.toggle()
+		}
+	}
+	l.scroll = max(min(l.scroll, len(l.Items)-l.visibleItems()), 0)
+}
+
+// Select selects the item at index and calls OnSelect. Indices out of range
+// are ignored.
+func (l *List) Select(index int) {
+	if index < 0 || index >= len(l.Items) || index == l.Selected {
+		return
+	}
+	l.Selected = index
+	if l.OnSelect != nil {
+		l.OnSelect(index)
+	}
+}
+
+func (l *List) toggle() {
+	if l.OnToggle != nil && l.Selected >= 0 && l.Selected < len(l.Items) {
+		l.OnToggle(l.Selected)
+	}
+}
+
+// reveal scrolls until the selected item is visible.
+func (l *List) reveal() {
+	if l.Selected < l.scroll {
+		l.scroll = l.Selected
+	}
+	if l.Selected >= l.scroll+l.visibleItems() {
+		l.scroll = l.Selected - l.visibleItems() + 1
+	}
+}
+
+func (l *List) Draw(screen *ebiten.Image) {
+	key := fmt.Sprint(strings.Join(l.Items, "\n"), l.Selected, l.scroll, l.focused)
+	l.drawCached(screen, key, func(dst *ebiten.Image) {
+		frame(dst, backgroundColor, l.focused)
+		for i := l.scroll; i < len(l.Items) && i < l.scroll+l.visibleItems(); i++ {
+			r := image.Rect(1, (i-l.scroll)*listItemHeight, dst.Bounds().Dx()-1, (i-l.scroll+1)*listItemHeight)
+			item := dst.SubImage(r).(*ebiten.Image)
+			if i == l.Selected {
+				item.Fill(pressedColor)
+			}
+			drawText(item, l.Items[i], 3)
+		}
+	})
+}
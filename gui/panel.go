@@ -0,0 +1,115 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+type Direction int
+
+const (
+	Row Direction = iota
+	Column
+)
+
+type Align int
+
+const (
+	AlignStart Align = iota
+	AlignCenter
+	AlignEnd
+)
+
+// Panel lays its children out in a row or a column, each at its preferred
+// size along the main axis and centred along the other one.
+type Panel struct {
+	base
+	Direction  Direction
+	Align      Align
+	Spacing    int
+	Padding    int
+	Background color.Color
+	children   []Widget
+}
+
+func NewPanel(direction Direction, children ...Widget) *Panel {
+	return &Panel{Direction: direction, Spacing: 4, children: children}
+}
+
+func (p *Panel) Children() []Widget {
+	return p.children
+}
+
+func (p *Panel) Add(widgets ...Widget) {
+	p.children = append(p.children, widgets...)
+	p.SetBounds(p.bounds)
+}
+
+// along splits a size into its main and cross axis components.
+func (p *Panel) along(width, height int) (int, int) {
+	if p.Direction == Row {
+		return width, height
+	}
+	return height, width
+}
+
+func (p *Panel) PreferredSize() (int, int) {
+	main, cross := 0, 0
+	for i, child := range p.children {
+		m, c := p.along(child.PreferredSize())
+		main += m
+		if i > 0 {
+			main += p.Spacing
+		}
+		cross = max(cross, c)
+	}
+	return p.along(main+2*p.Padding, cross+2*p.Padding)
+}
+
+func (p *Panel) SetBounds(bounds image.Rectangle) {
+	p.bounds = bounds
+
+	inner := bounds.Inset(p.Padding)
+	length, thickness := p.along(inner.Dx(), inner.Dy())
+	preferred, _ := p.along(p.PreferredSize())
+
+	offset := 0
+	switch p.Align {
+	case AlignCenter:
+		offset = (length - (preferred - 2*p.Padding)) / 2
+	case AlignEnd:
+		offset = length - (preferred - 2*p.Padding)
+	}
+
+	for _, child := range p.children {
+		m, c := p.along(child.PreferredSize())
+		c = min(c, thickness)
+		crossOffset := (thickness - c) / 2
+
+		var r image.Rectangle
+		if p.Direction == Row {
+			r = image.Rect(0, 0, m, c).Add(inner.Min.Add(image.Pt(offset, crossOffset)))
+		} else {
+			r = image.Rect(0, 0, c, m).Add(inner.Min.Add(image.Pt(crossOffset, offset)))
+		}
+		child.SetBounds(r)
+		offset += m + p.Spacing
+	}
+}
+
+func (p *Panel) Update(input Input) {
+	for _, child := range p.children {
+		child.Update(input)
+	}
+}
+
+func (p *Panel) Draw(screen *ebiten.Image) {
+	if p.Background != nil && !p.bounds.Empty() {
+		screen.SubImage(p.bounds).(*ebiten.Image).Fill(p.Background)
+	}
+	for _, child := range p.children {
+		child.Draw(screen)
+	}
+}
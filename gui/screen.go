@@ -0,0 +1,129 @@
+package gui
+
+import (
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Screen is the root of a widget tree. It moves the focus on click and with
+// Tab and Shift+Tab, and draws overlays above every other widget.
+type Screen struct {
+	Root    Widget
+	focused Focusable
+}
+
+func NewScreen(root Widget) *Screen {
+	return &Screen{Root: root}
+}
+
+func (s *Screen) SetBounds(bounds image.Rectangle) {
+	s.Root.SetBounds(bounds)
+}
+
+// Focused returns the focused widget, or nil.
+func (s *Screen) Focused() Focusable {
+	return s.focused
+}
+
+// CapturesKeyboard reports whether keys are used by a widget, in which case
+// the application should ignore them.
+func (s *Screen) CapturesKeyboard() bool {
+	if s.activeOverlay() != nil {
+		return true
+	}
+	_, ok := s.focused.(*TextInput)
+	return ok
+}
+
+// Contains reports whether the point is on one of the widgets.
+func (s *Screen) Contains(x, y int) bool {
+	p := image.Pt(x, y)
+	for _, w := range walk(s.Root) {
+		if _, ok := w.(Container); !ok && p.In(w.Bounds()) {
+			return true
+		}
+	}
+	return s.activeOverlay() != nil
+}
+
+func (s *Screen) Update(input Input) {
+	if overlay := s.activeOverlay(); overlay != nil {
+		overlay.Update(input)
+		return
+	}
+
+	focusables := make([]Focusable, 0)
+	for _, w := range walk(s.Root) {
+		if f, ok := w.(Focusable); ok {
+			focusables = append(focusables, f)
+		}
+	}
+
+	if input.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+		x, y := input.CursorPosition()
+		var clicked Focusable
+		for _, f := range focusables {
+			if image.Pt(x, y).In(f.Bounds()) {
+				clicked = f
+			}
+		}
+		s.focus(clicked)
+	}
+
+	if input.IsKeyJustPressed(ebiten.KeyTab) && len(focusables) > 0 {
+		index := -1
+		for i, f := range focusables {
+			if f == s.focused {
+				index = i
+			}
+		}
+		if !input.IsKeyPressed(ebiten.KeyShift) {
+			index = (index + 1) % len(focusables)
+		} else if index < 0 {
+			index = len(focusables) - 1
+		} else {
+			index = (index - 1 + len(focusables)) % len(focusables)
+		}
+		s.focus(focusables[index])
+	}
+
+	s.Root.Update(input)
+}
+
+func (s *Screen) focus(f Focusable) {
+	if s.focused != nil {
+		s.focused.SetFocused(false)
+	}
+	s.focused = f
+	if f != nil {
+		f.SetFocused(true)
+	}
+}
+
+func (s *Screen) Draw(screen *ebiten.Image) {
+	s.Root.Draw(screen)
+	if overlay := s.activeOverlay(); overlay != nil {
+		overlay.DrawOverlay(screen)
+	}
+}
+
+func (s *Screen) activeOverlay() Overlay {
+	for _, w := range walk(s.Root) {
+		if o, ok := w.(Overlay); ok && o.OverlayActive() {
+			return o
+		}
+	}
+	return nil
+}
+
+// walk returns w and every widget below it, depth first.
+func walk(w Widget) []Widget {
+	widgets := []Widget{w}
+	if c, ok := w.(Container); ok {
+		for _, child := range c.Children() {
+			widgets = append(widgets, walk(child)...)
+		}
+	}
+	return widgets
+}
@@ -0,0 +1,92 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Slider picks a value between Min and Max by dragging, or with the arrow
+// keys while focused. A positive Step snaps the value to multiples of Step.
+type Slider struct {
+	base
+	focus
+	Label    string
+	Min, Max float64
+	Step     float64
+	Value    float64
+	Width    int
+	OnChange func(value float64)
+	dragging bool
+}
+
+func NewSlider(label string, min, max, step, value float64, onChange func(value float64)) *Slider {
+	return &Slider{Label: label, Min: min, Max: max, Step: step, Value: value, Width: 100, OnChange: onChange}
+}
+
+func (s *Slider) PreferredSize() (int, int) {
+	return s.Width, 20
+}
+
+func (s *Slider) Update(input Input) {
+	if s.updateHover(input) && input.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+		s.dragging = true
+	}
+	if !input.IsMouseButtonPressed(ebiten.MouseButton0) {
+		s.dragging = false
+	}
+	if s.dragging {
+		x, _ := input.CursorPosition()
+		t := float64(x-s.bounds.Min.X) / float64(max(s.bounds.Dx(), 1))
+		s.set(s.Min + t*(s.Max-s.Min))
+	}
+
+	if s.focused {
+		step := s.Step
+		if step <= 0 {
+			step = (s.Max - s.Min) / 20
+		}
+		if input.IsKeyJustPressed(ebiten.KeyArrowLeft) {
+			s.set(s.Value - step)
+		}
+		if input.IsKeyJustPressed(ebiten.KeyArrowRight) {
+			s.set(s.Value + step)
+		}
+	}
+}
+
+func (s *Slider) set(value float64) {
+	if s.Step > 0 {
+		value = s.Min + math.Round((value-s.Min)/s.Step)*s.Step
+	}
+	value = math.Max(s.Min, math.Min(s.Max, value))
+	if value == s.Value {
+		return
+	}
+	s.Value = value
+	if s.OnChange != nil {
+		s.OnChange(value)
+	}
+}
+
+func (s *Slider) Draw(screen *ebiten.Image) {
+	text := fmt.Sprintf("%s %.2f", s.Label, s.Value)
+	if s.Step >= 1 {
+		text = fmt.Sprintf("%s %.0f", s.Label, s.Value)
+	}
+	key := fmt.Sprint(text, s.hover, s.focused)
+	s.drawCached(screen, key, func(dst *ebiten.Image) {
+		c := backgroundColor
+		if s.hover || s.dragging {
+			c = hoverColor
+		}
+		frame(dst, c, s.focused)
+
+		t := (s.Value - s.Min) / (s.Max - s.Min)
+		width := int(t * float64(dst.Bounds().Dx()-2))
+		fill(dst, image.Rect(1, dst.Bounds().Dy()-4, 1+width, dst.Bounds().Dy()-1), accentColor)
+		drawCenteredText(dst, text)
+	})
+}
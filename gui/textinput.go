@@ -0,0 +1,71 @@
+package gui
+
+import (
+	"fmt"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// TextInput edits a single line of text while focused. OnSubmit is called
+// with the text when Enter is pressed.
+type TextInput struct {
+	base
+	focus
+	Text     string
+	Width    int
+	OnSubmit func(text string)
+	ticks    int
+}
+
+func NewTextInput(text string, onSubmit func(text string)) *TextInput {
+	return &TextInput{Text: text, Width: 100, OnSubmit: onSubmit}
+}
+
+func (t *TextInput) PreferredSize() (int, int) {
+	return t.Width, 20
+}
+
+func (t *TextInput) Update(input Input) {
+	if !t.focused {
+		t.ticks = 0
+		return
+	}
+	t.ticks++
+
+	t.Text = string(input.AppendInputChars([]rune(t.Text)))
+
+	if repeated(input, ebiten.KeyBackspace) && len(t.Text) > 0 {
+		runes := []rune(t.Text)
+		t.Text = string(runes[:len(runes)-1])
+	}
+	if input.IsKeyJustPressed(ebiten.KeyEnter) && t.OnSubmit != nil {
+		t.OnSubmit(t.Text)
+	}
+}
+
+// repeated reports whether key was just pressed or has been held long enough
+// to repeat.
+func repeated(input Input, key ebiten.Key) bool {
+	d := input.KeyPressDuration(key)
+	return d == 1 || d >= 30 && d%3 == 0
+}
+
+func (t *TextInput) Draw(screen *ebiten.Image) {
+	caret := t.focused && t.ticks/30%2 == 0
+	key := fmt.Sprint(t.Text, t.focused, caret)
+	t.drawCached(screen, key, func(dst *ebiten.Image) {
+		frame(dst, backgroundColor, t.focused)
+
+		text := t.Text
+		if caret {
+			text += "_"
+		}
+		// Keep the end of the text visible.
+		runes := []rune(text)
+		fit := max((dst.Bounds().Dx()-8)/charWidth, 0)
+		if len(runes) > fit {
+			runes = runes[len(runes)-fit:]
+		}
+		drawText(dst, string(runes), 4)
+	})
+}
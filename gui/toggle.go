@@ -0,0 +1,53 @@
+package gui
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/hajimehoshi/ebiten/v2"
+)
+
+// Toggle is a labeled check box. OnChange receives the new value.
+type Toggle struct {
+	base
+	focus
+	Label    string
+	Value    bool
+	OnChange func(value bool)
+}
+
+func NewToggle(label string, value bool, onChange func(value bool)) *Toggle {
+	return &Toggle{Label: label, Value: value, OnChange: onChange}
+}
+
+func (t *Toggle) PreferredSize() (int, int) {
+	return textWidth(t.Label) + 24, 20
+}
+
+func (t *Toggle) Update(input Input) {
+	hovered := t.updateHover(input)
+	if input.IsMouseButtonJustPressed(ebiten.MouseButton0) && hovered || t.focused && input.IsKeyJustPressed(ebiten.KeyEnter) {
+		t.Value = !t.Value
+		if t.OnChange != nil {
+			t.OnChange(t.Value)
+		}
+	}
+}
+
+func (t *Toggle) Draw(screen *ebiten.Image) {
+	key := fmt.Sprint(t.Label, t.Value, t.hover, t.focused)
+	t.drawCached(screen, key, func(dst *ebiten.Image) {
+		c := backgroundColor
+		if t.hover {
+			c = hoverColor
+		}
+		frame(dst, c, t.focused)
+
+		box := image.Rect(4, (dst.Bounds().Dy()-12)/2, 16, (dst.Bounds().Dy()+12)/2)
+		fill(dst, box, pressedColor)
+		if t.Value {
+			fill(dst, box.Inset(3), accentColor)
+		}
+		drawText(dst, t.Label, 20)
+	})
+}
@@ -0,0 +1,141 @@
+package gui
+
+import (
+	"image"
+	"image/color"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+)
+
+// Size of a character of the debug font used for every text.
+const charWidth, charHeight = 6, 16
+
+var (
+	backgroundColor = color.RGBA{40, 40, 48, 255}
+	hoverColor      = color.RGBA{64, 64, 76, 255}
+	pressedColor    = color.RGBA{96, 96, 116, 255}
+	accentColor     = color.RGBA{200, 160, 40, 255}
+	focusColor      = color.RGBA{255, 255, 0, 255}
+)
+
+// Widget is anything that can be laid out, updated and drawn.
+type Widget interface {
+	Update(input Input)
+	Draw(screen *ebiten.Image)
+	Bounds() image.Rectangle
+	SetBounds(bounds image.Rectangle)
+	// PreferredSize is the size the widget gets from a Panel.
+	PreferredSize() (int, int)
+}
+
+// Focusable widgets receive keyboard input while focused.
+type Focusable interface {
+	Widget
+	SetFocused(focused bool)
+}
+
+// Container widgets hold other widgets.
+type Container interface {
+	Widget
+	Children() []Widget
+}
+
+// Overlay widgets draw above every other widget, like an open dropdown list.
+// While an overlay is active it is the only widget updated.
+type Overlay interface {
+	Widget
+	OverlayActive() bool
+	DrawOverlay(screen *ebiten.Image)
+}
+
+// base holds the state shared by every widget, including the image the
+// widget was last rendered to.
+type base struct {
+	bounds   image.Rectangle
+	hover    bool
+	cache    *ebiten.Image
+	cacheKey string
+}
+
+// focus is embedded by the widgets that implement Focusable.
+type focus struct {
+	focused bool
+}
+
+func (f *focus) SetFocused(focused bool) {
+	f.focused = focused
+}
+
+func (b *base) Bounds() image.Rectangle {
+	return b.bounds
+}
+
+func (b *base) SetBounds(bounds image.Rectangle) {
+	b.bounds = bounds
+}
+
+// updateHover records and returns whether the cursor is over the widget.
+func (b *base) updateHover(input Input) bool {
+	x, y := input.CursorPosition()
+	b.hover = image.Pt(x, y).In(b.bounds)
+	return b.hover
+}
+
+// drawCached draws the widget image, rendering it again only when the size
+// or key, a description of the widget state, changed since the last frame.
+func (b *base) drawCached(screen *ebiten.Image, key string, render func(dst *ebiten.Image)) {
+	width, height := b.bounds.Dx(), b.bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	if b.cache == nil || b.cache.Bounds().Dx() != width || b.cache.Bounds().Dy() != height {
+		if b.cache != nil {
+			b.cache.Deallocate()
+		}
+		b.cache = ebiten.NewImage(width, height)
+		b.cacheKey = ""
+	}
+	if b.cacheKey != key || key == "" {
+		b.cache.Clear()
+		render(b.cache)
+		b.cacheKey = key
+	}
+
+	op := &ebiten.DrawImageOptions{}
+	op.GeoM.Translate(float64(b.bounds.Min.X), float64(b.bounds.Min.Y))
+	screen.DrawImage(b.cache, op)
+}
+
+func fill(dst *ebiten.Image, r image.Rectangle, c color.Color) {
+	dst.SubImage(r).(*ebiten.Image).Fill(c)
+}
+
+// frame fills dst with c and outlines it when the widget is focused.
+func frame(dst *ebiten.Image, c color.Color, focused bool) {
+	dst.Fill(c)
+	if focused {
+		r := dst.Bounds()
+		fill(dst, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), focusColor)
+		fill(dst, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), focusColor)
+		fill(dst, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), focusColor)
+		fill(dst, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), focusColor)
+	}
+}
+
+func textWidth(s string) int {
+	return len([]rune(s)) * charWidth
+}
+
+// drawText draws s vertically centred in dst, starting x pixels from its
+// left edge. dst may be a sub-image.
+func drawText(dst *ebiten.Image, s string, x int) {
+	r := dst.Bounds()
+	ebitenutil.DebugPrintAt(dst, s, r.Min.X+x, r.Min.Y+(r.Dy()-charHeight)/2)
+}
+
+// drawCenteredText draws s centred in dst.
+func drawCenteredText(dst *ebiten.Image, s string) {
+	drawText(dst, s, (dst.Bounds().Dx()-textWidth(s))/2)
+}
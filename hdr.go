@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+	"image/color"
+)
+
+// floatColor is a color premultiplied by its alpha, the last component. Every
+// component is in [0,1].
+type floatColor [4]float32
+
+func colorFromRGBA(c color.RGBA) floatColor {
+	return floatColor{float32(c.R) / 255, float32(c.G) / 255, float32(c.B) / 255, float32(c.A) / 255}
+}
+
+// floatImage is what the renderer produces, before the colors are quantised
+// for the screen or a file.
+type floatImage struct {
+	width, height int
+	pix           []floatColor
+}
+
+func newFloatImage(width, height int) *floatImage {
+	return &floatImage{width, height, make([]floatColor, width*height)}
+}
+
+func (img *floatImage) at(x, y int) floatColor {
+	return img.pix[y*img.width+x]
+}
+
+func (img *floatImage) set(x, y int, c floatColor) {
+	img.pix[y*img.width+x] = c
+}
+
+// opaque reports whether every pixel has an alpha of 1.
+func (img *floatImage) opaque() bool {
+	for _, c := range img.pix {
+		if c[3] < 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// rgba quantises img to 8 bits per component.
+func (img *floatImage) rgba() *image.RGBA {
+	result := image.NewRGBA(image.Rect(0, 0, img.width, img.height))
+	for i, c := range img.pix {
+		for k, f := range c {
+			result.Pix[i*4+k] = toByte(f)
+		}
+	}
+	return result
+}
+
+// rgba64 quantises img to 16 bits per component.
+func (img *floatImage) rgba64() *image.RGBA64 {
+	result := image.NewRGBA64(image.Rect(0, 0, img.width, img.height))
+	for y := 0; y < img.height; y++ {
+		for x := 0; x < img.width; x++ {
+			c := img.at(x, y)
+			result.SetRGBA64(x, y, color.RGBA64{toWord(c[0]), toWord(c[1]), toWord(c[2]), toWord(c[3])})
+		}
+	}
+	return result
+}
+
+// toByte maps [0,1] to [0,255], clamping values outside of the range.
+func toByte(f float32) uint8 {
+	return uint8(min(max(f, 0), 1)*255 + 0.5)
+}
+
+// toWord maps [0,1] to [0,65535], clamping values outside of the range.
+func toWord(f float32) uint16 {
+	return uint16(min(max(f, 0), 1)*65535 + 0.5)
+}
@@ -0,0 +1,440 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	eqt "github.com/toantht/texturegen/equation"
+	"github.com/toantht/texturegen/parser"
+)
+
+// runEvolve evolves populations without a window. Parents are the most
+// novel individuals of each generation, so the run searches for diversity
+// only. With more than one island, sub-populations evolve in parallel and
+// trade migrants. The merged hall of fame is written to the output directory.
+func runEvolve(args []string) error {
+	flags := flag.NewFlagSet("evolve", flag.ContinueOnError)
+	generations := flags.Int("generations", 30, "number of generations")
+	population := flags.Int("population", 9, "individuals per generation and island")
+	parents := flags.Int("parents", 3, "individuals selected as parents")
+	fresh := flags.Int("fresh", 1, "random individuals injected per generation")
+	minDistance := flags.Float64("min-distance", defaultMinDistance, "minimum descriptor distance between children, 0 disables")
+	k := flags.Int("k", 5, "nearest neighbours used for the novelty score")
+	crossoverName := flags.String("crossover", crossoverSubtree.String(), "crossover mode")
+	islands := flags.Int("islands", 1, "number of islands evolving in parallel")
+	migrateEvery := flags.Int("migrate-every", 5, "generations between migrations, 0 disables")
+	migrants := flags.Int("migrants", 2, "individuals sent to each neighbour per migration")
+	topologyName := flags.String("topology", topologyRing.String(), "migration topology: ring or full")
+	hallOfFame := flags.Int("hall-of-fame", 0, "number of equations written, 0 writes all archived")
+	size := flags.Int("size", 256, "size of the rendered PNG files")
+	out := flags.String("out", "out", "output directory")
+	seed := flags.Int64("seed", time.Now().UnixNano(), "random seed, island i uses seed+i")
+	colorName := flags.String("color", colorRGB.String(), "color mode of new equations: rgb, hsv, hsl, oklab, ycbcr or palette")
+	paletteFile := flags.String("palette", "", "palette file used by the palette color mode, evolved at random if empty")
+	alpha := flags.Bool("alpha", false, "give new equations an alpha channel")
+	minVariance := flags.Float64("min-variance", defaultQualityGate.minVariance, "minimum color variance of a child, 0 disables")
+	maxNaN := flags.Float64("max-nan", defaultQualityGate.maxNaN, "maximum fraction of NaN pixels in a child, 0 disables")
+	minColors := flags.Int("min-colors", defaultQualityGate.minColors, "minimum number of distinct colors in a child, 0 disables")
+	qualityRetries := flags.Int("quality-retries", defaultQualityGate.retries, "times a rejected child is bred again, 0 disables the quality gate")
+	renderFlags := addRenderFlags(flags, exportOptions)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	renderOpts, err := renderFlags()
+	if err != nil {
+		return err
+	}
+
+	mode, err := parseCrossoverMode(*crossoverName)
+	if err != nil {
+		return err
+	}
+	topology, err := parseTopology(*topologyName)
+	if err != nil {
+		return err
+	}
+	colorMode, err := parseColorMode(*colorName)
+	if err != nil {
+		return err
+	}
+	var p palette
+	if *paletteFile != "" {
+		if p, err = loadPalette(*paletteFile); err != nil {
+			return err
+		}
+	}
+	if *population < 1 || *parents < 1 || *islands < 1 || *k < 1 || *size < 1 {
+		return fmt.Errorf("population, parents, islands, k and size must be positive")
+	}
+	if *generations < 0 || *migrateEvery < 0 || *migrants < 0 || *hallOfFame < 0 {
+		return fmt.Errorf("generations, migrate-every, migrants and hall-of-fame must not be negative")
+	}
+
+	log.Printf("seed %d", *seed)
+
+	cfg := islandConfig{
+		islands:      *islands,
+		generations:  *generations,
+		population:   *population,
+		parents:      *parents,
+		k:            *k,
+		migrateEvery: *migrateEvery,
+		migrants:     *migrants,
+		topology:     topology,
+		seed:         *seed,
+		options: evolveOptions{
+			crossover:   mode,
+			freshSlots:  *fresh,
+			minDistance: *minDistance,
+			retries:     5,
+			quality:     qualityGate{minVariance: *minVariance, maxNaN: *maxNaN, minColors: *minColors, retries: *qualityRetries},
+			colorMode:   colorMode,
+			palette:     p,
+			alpha:       *alpha,
+		},
+	}
+	archives := runIslands(cfg)
+
+	return writeEntries(*out, mergeHallOfFame(archives, *k, *hallOfFame), *size, renderOpts)
+}
+
+// runRender renders .eqt files to images in the chosen format. Every image
+// is named after its equation file. With -material, every equation gives a
+// set of maps for physically based rendering instead.
+func runRender(args []string) error {
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	width := flags.Int("width", 512, "image width")
+	height := flags.Int("height", 512, "image height")
+	formatName := flags.String("format", formatPNG.String(), "image format: png, png16, pfm or exr")
+	compressionName := flags.String("compression", exrZIP.String(), "exr compression: none or zip")
+	out := flags.String("out", "", "output directory, next to every equation file if empty")
+	writeMaterial := flags.Bool("material", false, "write albedo, height, normal, ao and roughness maps named after the equation")
+	heightSourceName := flags.String("height-source", defaultMaterialOptions.height.String(), "height map source: luminance, r, g, b or a")
+	strength := flags.Float64("strength", float64(defaultMaterialOptions.strength), "slope of a height difference of 1 between neighbouring pixels")
+	aoRadius := flags.Int("ao-radius", defaultMaterialOptions.aoRadius, "distance in pixels searched for occluders")
+	renderFlags := addRenderFlags(flags, exportOptions)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts, err := renderFlags()
+	if err != nil {
+		return err
+	}
+	format, err := parseImageFormat(*formatName)
+	if err != nil {
+		return err
+	}
+	compression, err := parseEXRCompression(*compressionName)
+	if err != nil {
+		return err
+	}
+	source, err := parseHeightSource(*heightSourceName)
+	if err != nil {
+		return err
+	}
+	materialOpts := materialOptions{height: source, strength: float32(*strength), aoRadius: *aoRadius}
+	if *width < 1 || *height < 1 {
+		return fmt.Errorf("width and height must be positive")
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no equation files given")
+	}
+	if *out != "" {
+		if err := os.MkdirAll(*out, 0o755); err != nil {
+			return err
+		}
+	}
+
+	for _, filename := range flags.Args() {
+		eq, err := readTextureEquation(filename)
+		if err != nil {
+			return err
+		}
+		dir := filepath.Dir(filename)
+		if *out != "" {
+			dir = *out
+		}
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		if source == heightAlpha && eq.a == nil && *writeMaterial {
+			return fmt.Errorf("%s has no alpha channel to take the height from", filename)
+		}
+
+		var images []materialMap
+		if *writeMaterial {
+			images = renderMaterial(eq, eq.viewport, opts, materialOpts, *width, *height).maps()
+		} else {
+			images = []materialMap{{"", renderFloat(eq, eq.viewport, opts, *width, *height)}}
+		}
+		for _, img := range images {
+			path := filepath.Join(dir, name+format.extension())
+			if img.name != "" {
+				path = filepath.Join(dir, name+"_"+img.name+format.extension())
+			}
+			if err := writeImage(path, img.image, format, compression); err != nil {
+				return err
+			}
+			log.Printf("wrote %s", path)
+		}
+	}
+	return nil
+}
+
+type rankedEquation struct {
+	archiveEntry
+	novelty float64
+}
+
+// rankByNovelty scores every equation against the archive and the rest of
+// the population, most novel first.
+func rankByNovelty(eqs []*textureEquation, archive *noveltyArchive) []rankedEquation {
+	behaviors := make([]behavior, len(eqs))
+	for i, eq := range eqs {
+		behaviors[i] = describe(eq)
+	}
+
+	ranked := make([]rankedEquation, len(eqs))
+	for i, eq := range eqs {
+		ranked[i] = rankedEquation{archiveEntry{eq, behaviors[i]}, archive.novelty(behaviors[i], behaviors)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].novelty > ranked[j].novelty })
+	return ranked
+}
+
+func writeEntries(dir string, entries []archiveEntry, size int, opts renderOptions) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i, entry := range entries {
+		name := filepath.Join(dir, fmt.Sprintf("%04d", i))
+		if err := writeTextureEquation(name+".eqt", entry.equation); err != nil {
+			return err
+		}
+		if err := writePNG(name+".png", renderTexture(entry.equation, entry.equation.viewport, opts, size, size)); err != nil {
+			return err
+		}
+	}
+	log.Printf("wrote %d equations to %s", len(entries), dir)
+	return nil
+}
+
+// addRenderFlags defines the flags choosing how the written images are
+// sampled and tonemapped. The returned function reads them once flags are
+// parsed.
+func addRenderFlags(flags *flag.FlagSet, defaults renderOptions) func() (renderOptions, error) {
+	samples := flags.Int("samples", defaults.sampling.samples, "samples per pixel side, 1 disables supersampling")
+	patternName := flags.String("pattern", defaults.sampling.pattern.String(), "sample pattern: grid or jittered")
+	filterName := flags.String("filter", defaults.sampling.filter.String(), "reconstruction filter: box, tent or gaussian")
+	adaptive := flags.Float64("adaptive", defaults.sampling.adaptive, "only supersample pixels whose neighbourhood deviates more than this, 0 supersamples all")
+	tonemapName := flags.String("tonemap", defaults.tonemap.mode.String(), "value mapping: wrap, clamp, sigmoid or normalize")
+	nanColor := flags.String("nan", fmt.Sprintf("%02x%02x%02x", defaults.tonemap.nan.R, defaults.tonemap.nan.G, defaults.tonemap.nan.B), "color of the pixels where a channel is NaN, as rrggbb")
+	return func() (renderOptions, error) {
+		pattern, err := parseSamplePattern(*patternName)
+		if err != nil {
+			return renderOptions{}, err
+		}
+		filter, err := parseFilter(*filterName)
+		if err != nil {
+			return renderOptions{}, err
+		}
+		if *samples < 1 {
+			return renderOptions{}, fmt.Errorf("samples must be positive")
+		}
+		mode, err := parseTonemapMode(*tonemapName)
+		if err != nil {
+			return renderOptions{}, err
+		}
+		nan, err := parseHexColor(*nanColor)
+		if err != nil {
+			return renderOptions{}, err
+		}
+		return renderOptions{
+			sampling: sampling{samples: *samples, pattern: pattern, filter: filter, adaptive: *adaptive},
+			tonemap:  tonemap{mode: mode, nan: nan},
+		}, nil
+	}
+}
+
+func parseCrossoverMode(name string) (crossoverMode, error) {
+	for _, mode := range crossoverModes {
+		if mode.String() == name {
+			return mode, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown crossover mode %q", name)
+}
+
+// runGraph writes a channel tree of an equation file as a Graphviz DOT or
+// SVG drawing, to standard output unless -out is given.
+func runGraph(args []string) error {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	formatName := flags.String("format", "dot", "output format: dot or svg")
+	channelName := flags.String("channel", "r", "channel tree: r, g, b or a")
+	ranges := flags.Bool("ranges", false, "annotate every node with the range of its values over the viewport")
+	out := flags.String("out", "", "output file, standard output if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("expected one equation file")
+	}
+	draw := map[string]func(eqt.BaseNode, eqt.GraphOptions) string{"dot": eqt.DOT, "svg": eqt.SVG}[*formatName]
+	if draw == nil {
+		return fmt.Errorf("unknown graph format %q", *formatName)
+	}
+	channel := slices.Index([]string{"r", "g", "b", "a"}, *channelName)
+	if channel < 0 {
+		return fmt.Errorf("unknown channel %q", *channelName)
+	}
+
+	eq, err := readTextureEquation(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	channels := eq.channels()
+	if channel >= len(channels) {
+		return fmt.Errorf("%s has no alpha channel", flags.Arg(0))
+	}
+	opts := eqt.GraphOptions{Annotate: *ranges}
+	opts.X, opts.Y = eq.viewport.bounds()
+	if channel == 1 {
+		// The green channel is evaluated with x and y swapped.
+		opts.X, opts.Y = opts.Y, opts.X
+	}
+
+	graph := draw(*channels[channel], opts)
+	if *out == "" {
+		_, err = fmt.Print(graph)
+		return err
+	}
+	return os.WriteFile(*out, []byte(graph), 0o644)
+}
+
+// runFmt rewrites .eqt files in their canonical layout, the one the
+// application writes: settings that differ from the defaults, then one
+// channel per line, names as the parser knows them. Constants get 9
+// decimals in the prefix syntax and their shortest exact form in the infix
+// syntax. Files keep their syntax. Directories are searched for .eqt files.
+//
+// Comments at the top of a file are kept. Other comments cannot be placed
+// in the rewritten tree, so files that have them are left alone with an
+// error.
+//
+// With -check, the files that are not formatted are listed and left alone,
+// and the command fails if there are any.
+func runFmt(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list the files that are not formatted instead of rewriting them")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return fmt.Errorf("no equation files given")
+	}
+
+	files := make([]string, 0)
+	for _, arg := range flags.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, entry fs.DirEntry, err error) error {
+			if err == nil && !entry.IsDir() && filepath.Ext(path) == ".eqt" {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	unformatted := 0
+	for _, filename := range files {
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			return err
+		}
+		formatted, err := formatSource(string(src))
+		if err != nil {
+			return fmt.Errorf("%s: %w", filename, err)
+		}
+		if formatted == string(src) {
+			continue
+		}
+		unformatted++
+		if *check {
+			fmt.Println(filename)
+			continue
+		}
+		if err := os.WriteFile(filename, []byte(formatted), 0o644); err != nil {
+			return err
+		}
+		log.Printf("formatted %s", filename)
+	}
+	if *check && unformatted > 0 {
+		return fmt.Errorf("%d of %d files are not formatted", unformatted, len(files))
+	}
+	return nil
+}
+
+// formatSource returns the canonical text of the equation in src. It fails
+// rather than return a text that reads back as a different equation.
+func formatSource(src string) (string, error) {
+	header, body := splitHeader(src)
+	if strings.Contains(body, "#") || strings.Contains(body, "//") {
+		return "", fmt.Errorf("comments inside the equation would be lost, move them to the top")
+	}
+	t, err := parseTextureEquation(body)
+	if err != nil {
+		return "", err
+	}
+	formatted := formatTextureEquation(t, parser.IsInfix(body))
+	again, err := parseTextureEquation(formatted)
+	if err != nil {
+		return "", fmt.Errorf("formatted equation does not parse: %w", err)
+	}
+	if formatTextureEquation(again, parser.IsInfix(body)) != formatted {
+		return "", fmt.Errorf("formatting is not stable")
+	}
+	for i, channel := range t.channels() {
+		// Trees are the same if they make a single tree of a DAG, which
+		// compares constants by their bits.
+		if d := eqt.NewDAG(*channel, *again.channels()[i]); d.Roots[0] != d.Roots[1] {
+			return "", fmt.Errorf("formatting changes channel %c", "rgba"[i])
+		}
+	}
+	if header != "" {
+		formatted = header + "\n" + formatted
+	}
+	return formatted, nil
+}
+
+// splitHeader splits src after its leading comment lines. The header loses
+// its blank lines and trailing spaces, and a byte order mark.
+func splitHeader(src string) (string, string) {
+	lines := strings.Split(strings.TrimPrefix(src, "\uFEFF"), "\n")
+	header := make([]string, 0)
+	for len(lines) > 0 {
+		line := strings.TrimSpace(lines[0])
+		if line != "" && !strings.HasPrefix(line, "#") && !strings.HasPrefix(line, "//") {
+			break
+		}
+		if line != "" {
+			header = append(header, strings.TrimRight(lines[0], " \t\r"))
+		}
+		lines = lines[1:]
+	}
+	return strings.Join(header, "\n"), strings.Join(lines, "\n")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"prefix",
+			"(EquationImage\tPlus(X,Y) Y\n\nX)",
+			"(EquationImage \nPlus(X, Y)\nY\nX)\n",
+		},
+		{
+			"header comments",
+			"\uFEFF# title\n\n// author  \n(EquationImage X Y X)",
+			"# title\n// author\n(EquationImage \nX\nY\nX)\n",
+		},
+		{
+			"unknown settings",
+			"(EquationImage Future(1, 2) ColorMode(hsv) X Y X)",
+			"(EquationImage \nColorMode(hsv)\nFuture(1, 2)\nX\nY\nX)\n",
+		},
+		{
+			"small constant",
+			"(EquationImage Plus(X, 1.234567e-7) Y X)",
+			"(EquationImage \nPlus(X, 1.234567e-07)\nY\nX)\n",
+		},
+		{
+			"infix",
+			"r = 0 - 3; g = x*y\nb = -inf",
+			"r = -(3)\ng = x * y\nb = -inf\n",
+		},
+	}
+	for _, test := range tests {
+		got, err := formatSource(test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: formatted as %q, want %q", test.name, got, test.want)
+		}
+		if again, err := formatSource(got); err != nil || again != got {
+			t.Errorf("%s: formatting again gives %q, %v", test.name, again, err)
+		}
+	}
+}
+
+func TestFormatSourceKeepsInnerComments(t *testing.T) {
+	for _, src := range []string{
+		"(EquationImage\n# red\nX Y X)",
+		"r = x // red\ng = y\nb = x",
+	} {
+		if _, err := formatSource(src); err == nil || !strings.Contains(err.Error(), "comments") {
+			t.Errorf("%q: got %v, want an error about comments", src, err)
+		}
+	}
+}
+
+func TestRunEvolveRejectsInvalidCounts(t *testing.T) {
+	for _, flag := range []string{
+		"-population=0", "-parents=0", "-islands=0", "-k=0", "-size=0",
+		"-generations=-1", "-migrate-every=-1", "-migrants=-1", "-hall-of-fame=-1",
+	} {
+		out := t.TempDir()
+		err := runEvolve([]string{"-out", out, flag})
+		if err == nil || !strings.Contains(err.Error(), "must") {
+			t.Errorf("%s: got %v, want an error", flag, err)
+		}
+		if entries, _ := os.ReadDir(out); len(entries) > 0 {
+			t.Errorf("%s: wrote %d files", flag, len(entries))
+		}
+	}
+}
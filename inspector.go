@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/hajimehoshi/ebiten/v2/ebitenutil"
+	"github.com/hajimehoshi/ebiten/v2/vector"
+	eqt "github.com/toantht/texturegen/equation"
+	"github.com/toantht/texturegen/gui"
+)
+
+const inspectorLineHeight = 16
+const inspectorCharWidth = 6
+
+// inspector is drawn over the zoom view and lists every channel of an
+// equation with its statistics and pretty-printed formula, in the prefix
+// syntax of .eqt files or in the infix syntax.
+type inspector struct {
+	equation *textureEquation
+	infix    bool
+	lines    []string
+	width    int
+	scroll   int
+	message  string
+}
+
+func newInspector(equation *textureEquation) *inspector {
+	return &inspector{equation: equation}
+}
+
+// layout breaks the text for a screen of the given width in pixels.
+func (in *inspector) layout(width int) {
+	if width == in.width && in.lines != nil {
+		return
+	}
+	in.width = width
+	columns := max(width/inspectorCharWidth-2, 20)
+
+	in.lines = []string{"[I] close  [C] copy  [S] syntax  [Up/Down/Wheel] scroll", ""}
+	names := []string{"R", "G", "B", "A"}
+	ranges := in.equation.ranges()
+	for i, channel := range in.equation.channels() {
+		node := *channel
+		in.lines = append(in.lines, fmt.Sprintf("%s: %d nodes, depth %d", names[i], node.NodeCount(), eqt.Depth(node)))
+		in.lines = append(in.lines, describeRange(ranges[i]))
+
+		counts := make([]string, 0)
+		for _, op := range eqt.OpHistogram(node) {
+			counts = append(counts, fmt.Sprintf("%s %d", op.Name, op.Count))
+		}
+		in.lines = append(in.lines, wrap(strings.Join(counts, ", "), ", ", columns)...)
+		if in.infix {
+			in.lines = append(in.lines, wrap(strings.ToLower(names[i])+" = "+eqt.Infix(node), " ", columns)...)
+		} else {
+			in.lines = append(in.lines, strings.Split(eqt.Pretty(node, columns, "  "), "\n")...)
+		}
+		in.lines = append(in.lines, "")
+	}
+}
+
+// describeRange formats the bounds of a channel over the viewport.
+func describeRange(r eqt.Interval) string {
+	line := fmt.Sprintf("range %.3g to %.3g", r.Low, r.High)
+	if r.NaN {
+		line += ", may be NaN"
+	}
+	return line
+}
+
+// wrap breaks s into lines of at most columns characters at sep. Broken
+// lines keep what sep has before its spaces.
+func wrap(s, sep string, columns int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Split(s, sep) {
+		if line != "" && len(line)+len(word)+len(sep) > columns {
+			lines = append(lines, line+strings.TrimRight(sep, " "))
+			line = ""
+		}
+		if line != "" {
+			line += sep
+		}
+		line += word
+	}
+	return append(lines, line)
+}
+
+func (in *inspector) update(input gui.Input, height int) {
+	visible := height/inspectorLineHeight - 1
+	_, wheel := input.Wheel()
+	switch {
+	case wheel > 0 || input.IsKeyJustPressed(ebiten.KeyArrowUp):
+		in.scroll--
+	case wheel < 0 || input.IsKeyJustPressed(ebiten.KeyArrowDown):
+		in.scroll++
+	case input.IsKeyJustPressed(ebiten.KeyPageUp):
+		in.scroll -= visible
+	case input.IsKeyJustPressed(ebiten.KeyPageDown):
+		in.scroll += visible
+	}
+	in.scroll = max(min(in.scroll, len(in.lines)-visible), 0)
+
+	if input.IsKeyJustPressed(ebiten.KeyS) {
+		in.infix = !in.infix
+		in.lines = nil
+	}
+	if input.IsKeyJustPressed(ebiten.KeyC) {
+		text := in.equation.String()
+		if in.infix {
+			text = in.equation.infix()
+		}
+		if err := copyToClipboard(text); err != nil {
+			in.message = "copy failed: " + err.Error()
+		} else {
+			in.message = "copied to clipboard"
+		}
+	}
+}
+
+func (in *inspector) draw(screen *ebiten.Image) {
+	width, height := screen.Bounds().Dx(), screen.Bounds().Dy()
+	in.layout(width)
+
+	vector.DrawFilledRect(screen, 0, 0, float32(width), float32(height), color.RGBA{0, 0, 0, 200}, false)
+	for i, line := range in.lines[in.scroll:] {
+		y := i * inspectorLineHeight
+		if y+inspectorLineHeight > height-inspectorLineHeight {
+			break
+		}
+		ebitenutil.DebugPrintAt(screen, line, inspectorCharWidth, y)
+	}
+	if in.message != "" {
+		ebitenutil.DebugPrintAt(screen, in.message, inspectorCharWidth, height-inspectorLineHeight)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+)
+
+type topology int
+
+const (
+	topologyRing topology = iota
+	topologyFull
+)
+
+func (t topology) String() string {
+	switch t {
+	case topologyRing:
+		return "ring"
+	case topologyFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+func parseTopology(name string) (topology, error) {
+	for _, t := range []topology{topologyRing, topologyFull} {
+		if t.String() == name {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown topology %q", name)
+}
+
+// linked reports whether island from sends migrants to island to.
+func (t topology) linked(from, to, islands int) bool {
+	if from == to {
+		return false
+	}
+	switch t {
+	case topologyRing:
+		return (from+1)%islands == to
+	case topologyFull:
+		return true
+	}
+	return false
+}
+
+type islandConfig struct {
+	islands     int
+	generations int
+	population  int
+	parents     int
+	k           int
+
+	// Every migrateEvery generations each island sends copies of its
+	// migrants most novel individuals to its neighbours, where they replace
+	// the least novel ones.
+	migrateEvery int
+	migrants     int
+	topology     topology
+
+	// seed+i seeds island i, so a run is reproducible from seed alone.
+	seed    int64
+	options evolveOptions
+}
+
+// runIslands evolves every island in its own goroutine and returns their
+// archives in island order.
+func runIslands(cfg islandConfig) []*noveltyArchive {
+	// links[from][to] carries migrants between two islands. Each link is
+	// buffered for every migration of the run so sending never blocks.
+	rounds := 0
+	if cfg.migrateEvery > 0 {
+		rounds = cfg.generations / cfg.migrateEvery
+	}
+	links := make([][]chan []*textureEquation, cfg.islands)
+	for from := range links {
+		links[from] = make([]chan []*textureEquation, cfg.islands)
+		for to := range links[from] {
+			if cfg.topology.linked(from, to, cfg.islands) {
+				links[from][to] = make(chan []*textureEquation, rounds)
+			}
+		}
+	}
+
+	archives := make([]*noveltyArchive, cfg.islands)
+	var wg sync.WaitGroup
+	for i := range cfg.islands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			archives[i] = runIsland(i, cfg, links)
+		}()
+	}
+	wg.Wait()
+	return archives
+}
+
+func runIsland(id int, cfg islandConfig, links [][]chan []*textureEquation) *noveltyArchive {
+	rng := rand.New(rand.NewSource(cfg.seed + int64(id)))
+	archive := newNoveltyArchive(cfg.k, 0)
+	var stats rejectionStats
+
+	eqs := make([]*textureEquation, cfg.population)
+	for i := range eqs {
+		eqs[i] = cfg.options.newEquation(rng)
+	}
+
+	for gen := 0; gen < cfg.generations; gen++ {
+		ranked := rankByNovelty(eqs, archive)
+		if cfg.islands > 1 && cfg.migrateEvery > 0 && gen > 0 && gen%cfg.migrateEvery == 0 {
+			ranked = rankByNovelty(migrate(id, ranked, cfg, links), archive)
+		}
+
+		selected := ranked[:min(cfg.parents, len(ranked))]
+		parents := make([]*textureEquation, len(selected))
+		for i, entry := range selected {
+			archive.add(entry.equation, entry.behavior)
+			parents[i] = entry.equation
+		}
+		log.Printf("island %d generation %d: best novelty %.4f, archive %d", id, gen, ranked[0].novelty, len(archive.entries))
+
+		var generationStats rejectionStats
+		eqs, generationStats = evolve(parents, cfg.population, cfg.options, rng)
+		stats.add(generationStats)
+	}
+	log.Printf("island %d: %s", id, stats)
+	return archive
+}
+
+// migrate sends copies of the best ranked individuals to every neighbour and
+// replaces the worst ones with the individuals received. Links are read in
+// island order so the result does not depend on goroutine scheduling.
+func migrate(id int, ranked []rankedEquation, cfg islandConfig, links [][]chan []*textureEquation) []*textureEquation {
+	count := min(cfg.migrants, len(ranked))
+	for to, link := range links[id] {
+		if link == nil {
+			continue
+		}
+		migrants := make([]*textureEquation, count)
+		for i := range migrants {
+			migrants[i] = copyTextureEquation(ranked[i].equation)
+		}
+		links[id][to] <- migrants
+	}
+
+	eqs := make([]*textureEquation, len(ranked))
+	for i, entry := range ranked {
+		eqs[i] = entry.equation
+	}
+
+	slot := len(eqs) - 1
+	for from := range links {
+		link := links[from][id]
+		if link == nil {
+			continue
+		}
+		for _, migrant := range <-link {
+			if slot < 0 {
+				break
+			}
+			eqs[slot] = migrant
+			slot--
+		}
+	}
+	return eqs
+}
+
+// mergeHallOfFame ranks the entries of every archive against each other and
+// keeps the n most novel ones, or all of them when n is zero. Migrants
+// archived by several islands are only kept once.
+func mergeHallOfFame(archives []*noveltyArchive, k int, n int) []archiveEntry {
+	eqs := make([]*textureEquation, 0)
+	seen := make(map[uint64]bool)
+	for _, archive := range archives {
+		for _, entry := range archive.entries {
+			if h := entry.equation.hash(); !seen[h] {
+				seen[h] = true
+				eqs = append(eqs, entry.equation)
+			}
+		}
+	}
+	if len(eqs) == 0 {
+		return nil
+	}
+
+	ranked := rankByNovelty(eqs, newNoveltyArchive(k, 0))
+	if n > 0 {
+		ranked = ranked[:min(n, len(ranked))]
+	}
+
+	entries := make([]archiveEntry, len(ranked))
+	for i, entry := range ranked {
+		entries[i] = entry.archiveEntry
+	}
+	return entries
+}
@@ -0,0 +1,48 @@
+package main
+
+// windowScale is the number of window pixels per logical screen pixel.
+const windowScale = 2
+
+// toolbarHeight is the space kept below the grid for the buttons.
+const toolbarHeight = 50
+
+// gridLayout places the textures of a rows by cols grid on the screen.
+type gridLayout struct {
+	screenWidth, screenHeight   int
+	rows, cols                  int
+	textureWidth, textureHeight float32
+	paddingWidth, paddingHeight float32
+	borderWidth                 float32
+}
+
+func newGridLayout(screenWidth, screenHeight, rows, cols int) gridLayout {
+	screenWidth = max(screenWidth, cols*10)
+	screenHeight = max(screenHeight, toolbarHeight+rows*10)
+
+	textureWidth := float32(screenWidth/cols) * 0.9
+	textureHeight := float32((screenHeight-toolbarHeight)/rows) * 0.9
+	return gridLayout{
+		screenWidth:   screenWidth,
+		screenHeight:  screenHeight,
+		rows:          rows,
+		cols:          cols,
+		textureWidth:  textureWidth,
+		textureHeight: textureHeight,
+		paddingWidth:  float32(screenWidth) * 0.1 / float32(cols+1),
+		paddingHeight: float32(screenHeight) * 0.1 / float32(rows+1),
+		borderWidth:   min(textureWidth/20, 2),
+	}
+}
+
+func (l gridLayout) count() int {
+	return l.rows * l.cols
+}
+
+// position returns the top left corner of the texture at index.
+func (l gridLayout) position(index int) (int, int) {
+	col := index % l.cols
+	row := index / l.cols
+	x := l.paddingWidth*float32(col+1) + l.textureWidth*float32(col)
+	y := l.paddingHeight*float32(row+1) + l.textureHeight*float32(row)
+	return int(x), int(y)
+}
@@ -1,100 +1,181 @@
 package main
 
 import (
+	"encoding/binary"
+	"flag"
 	"fmt"
+	"hash/fnv"
+	"image"
 	"image/color"
+	"image/png"
 	"log"
+	"math"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"math/rand"
 
 	"github.com/hajimehoshi/ebiten/v2"
-	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	eqt "github.com/toantht/texturegen/equation"
 	"github.com/toantht/texturegen/gui"
 	"github.com/toantht/texturegen/parser"
 )
 
-var screenWidth, screenHeight int = 1920 / 4, 1080 / 4
-
-var rows, cols int = 3, 3
-var numOfTextures = rows * cols
-var textureWidth = float32(screenWidth/cols) * 0.9
-var textureHeight = float32((screenHeight-50)/rows) * 0.9
-var paddingWidth = float32(screenWidth) * 0.1 / float32(cols+1)
-var paddingHeight = float32(screenHeight) * 0.1 / float32(rows+1)
-var borderWidth = min(textureWidth/20, 2)
-
 type texture struct {
-	index    int
-	equation *textureEquation
-	image    *ebiten.Image
-	x, y     int
-	selected bool
+	index         int
+	equation      *textureEquation
+	image         *ebiten.Image
+	x, y          int
+	width, height int
+	borderWidth   float32
+	border        *ebiten.Image
+	selected      bool
 }
 
-func NewTexture(index int) *texture {
-	equation := NewTextureEquation()
-	image := generateTexture(equation, int(textureWidth), int(textureHeight))
-	col := index % cols
-	row := index / cols
-	x := paddingWidth*float32(col+1) + textureWidth*float32(col)
-	y := paddingHeight*float32(row+1) + textureHeight*float32(row)
-
-	t := &texture{index, equation, image, int(x), int(y), false}
+func NewTexture(index int, layout gridLayout) *texture {
+	t := &texture{index: index, equation: NewTextureEquation(eqt.DefaultRand)}
+	t.place(layout)
+	t.image = generateTexture(t.equation, t.width, t.height)
 	return t
 }
 
-func crossover(a *textureEquation, b *textureEquation) *textureEquation {
-	aq := copyTextureEquation(a)
-	aColor := aq.pickRandomColor()
-	aNode := eqt.PickRandomNode(aColor)
+// place moves and resizes t to its cell in layout. It reports whether the
+// size changed, in which case the image needs to be generated again.
+func (t *texture) place(layout gridLayout) bool {
+	width, height := int(layout.textureWidth), int(layout.textureHeight)
+	resized := width != t.width || height != t.height
 
-	bColor := b.pickRandomColor()
-	bNode := eqt.PickRandomNode(bColor)
+	t.x, t.y = layout.position(t.index)
+	t.width, t.height = width, height
+	t.borderWidth = layout.borderWidth
+	return resized
+}
 
-	eqt.ReplaceNode(aNode, bNode)
-	return aq
+func (t *texture) contains(x, y int) bool {
+	return x >= t.x && x <= t.x+t.width && y >= t.y && y <= t.y+t.height
 }
 
-func evolve(selectedEquations []*textureEquation) []*textureEquation {
-	eqs := make([]*textureEquation, numOfTextures)
+type evolveOptions struct {
+	crossover crossoverMode
 
-	n := len(selectedEquations)
-	i := 0
-	for i < numOfTextures {
-		a := selectedEquations[rand.Intn(n)]
-		b := selectedEquations[rand.Intn(n)]
-		eqs[i] = crossover(a, b)
-		i++
+	// freshSlots is the number of children replaced by new random equations.
+	freshSlots int
+
+	// Children closer than minDistance to another child or to the archive are
+	// bred again, at most retries times. Zero disables the check.
+	minDistance float64
+	retries     int
+	archive     *noveltyArchive
+
+	// quality rejects degenerate children before the novelty check.
+	quality qualityGate
+
+	// New random equations use colorMode. In colorPalette they use palette,
+	// or a random one if it is nil.
+	colorMode colorMode
+	palette   palette
+	// alpha gives new random equations an alpha channel.
+	alpha bool
+}
+
+// newEquation returns a random equation with the color settings of opts.
+func (opts evolveOptions) newEquation(rng eqt.Rand) *textureEquation {
+	eq := NewTextureEquation(rng)
+	if opts.alpha {
+		eq.a = randomEquation(rng.Intn(100)+1, rng)
+	}
+	eq.colorMode = opts.colorMode
+	if opts.colorMode == colorPalette {
+		eq.palette = opts.palette
+		if eq.palette == nil {
+			eq.palette = randomPalette(rng)
+		}
 	}
+	return eq
+}
 
-	for _, eq := range eqs {
-		n := rand.Intn(4)
-		for i := 0; i < n; i++ {
-			eq.mutate()
+// evolve returns count children of selectedEquations and the stats of the
+// quality gate.
+func evolve(selectedEquations []*textureEquation, count int, opts evolveOptions, rng eqt.Rand) ([]*textureEquation, rejectionStats) {
+	eqs := make([]*textureEquation, 0, count)
+	behaviors := make([]behavior, 0, count)
+	var stats rejectionStats
+
+	fresh := min(opts.freshSlots, count)
+	for len(eqs) < count {
+		var eq *textureEquation
+		var b behavior
+		rejected := 0
+		for try := 0; ; {
+			if len(eqs) >= count-fresh {
+				eq = opts.newEquation(rng)
+			} else {
+				eq = breed(selectedEquations, opts.crossover, rng)
+			}
+			if rejected < opts.quality.retries {
+				reason := opts.quality.check(eq)
+				stats.checked++
+				stats.counts[reason]++
+				if reason != accepted {
+					rejected++
+					continue
+				}
+			}
+			if opts.minDistance <= 0 {
+				break
+			}
+			b = describe(eq)
+			if try >= opts.retries || !tooSimilar(b, behaviors, opts.archive, opts.minDistance) {
+				break
+			}
+			try++
 		}
+		eqs = append(eqs, eq)
+		behaviors = append(behaviors, b)
 	}
-	return eqs
+
+	if opts.archive != nil {
+		for i, eq := range eqs {
+			if behaviors[i] == nil {
+				behaviors[i] = describe(eq)
+			}
+			opts.archive.add(eq, behaviors[i])
+		}
+	}
+	return eqs, stats
+}
+
+// breed crosses two random parents and mutates the child a few times.
+func breed(selectedEquations []*textureEquation, mode crossoverMode, rng eqt.Rand) *textureEquation {
+	n := len(selectedEquations)
+	a := selectedEquations[rng.Intn(n)]
+	b := selectedEquations[rng.Intn(n)]
+	eq := crossover(a, b, mode, rng)
+
+	m := rng.Intn(4)
+	for i := 0; i < m; i++ {
+		eq.mutate(rng)
+	}
+	return eq
 }
 
 func (t *texture) applyEquation(e *textureEquation) {
 	t.equation = e
-	image := generateTexture(t.equation, int(textureWidth), int(textureHeight))
+	image := generateTexture(t.equation, t.width, t.height)
 	t.image = image
 }
 
 func (t *texture) mutate() {
-	t.equation.mutate()
-	image := generateTexture(t.equation, int(textureWidth), int(textureHeight))
+	t.equation.mutate(eqt.DefaultRand)
+	image := generateTexture(t.equation, t.width, t.height)
 	t.image = image
 }
 
-func (t *texture) update() {
-	mx, my := ebiten.CursorPosition()
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButton0) {
-		if mx >= t.x && mx <= t.x+int(textureWidth) && my >= t.y && my <= t.y+int(textureHeight) {
+func (t *texture) update(input gui.Input) {
+	mx, my := input.CursorPosition()
+	if input.IsMouseButtonJustPressed(ebiten.MouseButton0) {
+		if t.contains(mx, my) {
 			t.selected = !t.selected
 		}
 	}
@@ -102,41 +183,168 @@ func (t *texture) update() {
 
 func (t *texture) draw(screen *ebiten.Image) {
 	if t.selected {
-		border := ebiten.NewImage(int(textureWidth+borderWidth*2), int(textureHeight+borderWidth*2))
-		border.Fill(color.RGBA{255, 255, 0, 255})
+		width, height := int(float32(t.width)+t.borderWidth*2), int(float32(t.height)+t.borderWidth*2)
+		if t.border == nil || t.border.Bounds().Dx() != width || t.border.Bounds().Dy() != height {
+			t.border = ebiten.NewImage(width, height)
+			t.border.Fill(color.RGBA{255, 255, 0, 255})
+		}
 		borderOp := &ebiten.DrawImageOptions{}
-		borderOp.GeoM.Translate(float64(t.x)-float64(borderWidth), float64(t.y)-float64(borderWidth))
-		screen.DrawImage(border, borderOp)
+		borderOp.GeoM.Translate(float64(t.x)-float64(t.borderWidth), float64(t.y)-float64(t.borderWidth))
+		screen.DrawImage(t.border, borderOp)
 	}
 
 	op := &ebiten.DrawImageOptions{}
 	op.GeoM.Translate(float64(t.x), float64(t.y))
+	if t.equation.a != nil {
+		screen.DrawImage(checkerboard(t.width, t.height), op)
+	}
 	screen.DrawImage(t.image, op)
 }
 
+var checkerboardImage *ebiten.Image
+
+// checkerboard returns a width by height pattern drawn behind textures with
+// an alpha channel.
+func checkerboard(width, height int) *ebiten.Image {
+	bounds := image.Rectangle{}
+	if checkerboardImage != nil {
+		bounds = checkerboardImage.Bounds()
+	}
+	if width > bounds.Dx() || height > bounds.Dy() {
+		width, height := max(width, bounds.Dx()), max(height, bounds.Dy())
+		pattern := image.NewRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				c := color.RGBA{102, 102, 102, 255}
+				if (x/8+y/8)%2 == 0 {
+					c = color.RGBA{153, 153, 153, 255}
+				}
+				pattern.SetRGBA(x, y, c)
+			}
+		}
+		checkerboardImage = ebiten.NewImageFromImage(pattern)
+	}
+	return checkerboardImage.SubImage(image.Rect(0, 0, width, height)).(*ebiten.Image)
+}
+
 type textureEquation struct {
 	r eqt.BaseNode
 	g eqt.BaseNode
 	b eqt.BaseNode
+	// a is the alpha channel, nil for opaque textures.
+	a eqt.BaseNode
+
+	viewport  viewport
+	colorMode colorMode
+	// palette maps the first tree to a color in colorPalette. nil is the
+	// gray palette.
+	palette palette
+	// unknown holds the settings read from a file that this version does
+	// not know. They are written back unchanged.
+	unknown []eqt.Setting
 }
 
 func (t *textureEquation) String() string {
-	return "(EquationImage \n" + t.r.String() + "\n" + t.g.String() + "\n" + t.b.String() + ")"
+	return t.image().String()
+}
+
+// infix returns t in the infix syntax.
+func (t *textureEquation) infix() string {
+	return eqt.Infix(t.image())
 }
 
-func NewTextureEquation() *textureEquation {
-	opNodeCount := rand.Intn(100) + 1
+// image returns an image node holding the settings and the channel trees of
+// t. The trees are not copied.
+func (t *textureEquation) image() *eqt.OpImage {
+	image := eqt.NewOpImage()
+	children := []eqt.BaseNode{t.r, t.g, t.b}
+	if t.a != nil {
+		children = append(children, t.a)
+	}
+	image.SetChildren(children)
+	image.Settings = t.settings()
+	return image
+}
 
-	t := &textureEquation{}
-	t.r = randomEquation(opNodeCount)
-	t.g = randomEquation(opNodeCount)
-	t.b = randomEquation(opNodeCount)
+// settings lists the settings that differ from the defaults.
+func (t *textureEquation) settings() []eqt.Setting {
+	settings := make([]eqt.Setting, 0)
+	if t.viewport != defaultViewport {
+		settings = append(settings, t.viewport.setting())
+	}
+	if t.colorMode != colorRGB {
+		settings = append(settings, eqt.Setting{Name: "ColorMode", Args: []string{t.colorMode.String()}})
+	}
+	if t.colorMode == colorPalette && t.palette != nil {
+		settings = append(settings, t.palette.setting())
+	}
+	return append(settings, t.unknown...)
+}
+
+// textureEquationFromImage reads the channels and settings of a parsed .eqt
+// file.
+func textureEquationFromImage(node eqt.BaseNode) (*textureEquation, error) {
+	image, ok := node.(*eqt.OpImage)
+	if !ok {
+		return nil, fmt.Errorf("not an EquationImage")
+	}
+	children := image.GetChildren()
+	for _, child := range children {
+		if child == nil {
+			return nil, fmt.Errorf("EquationImage has missing channels")
+		}
+	}
+	t := &textureEquation{r: children[0], g: children[1], b: children[2], viewport: defaultViewport}
+	if len(children) > 3 {
+		t.a = children[3]
+	}
+	if s, ok := image.Setting("Viewport"); ok {
+		vp, err := parseViewport(s)
+		if err != nil {
+			return nil, err
+		}
+		t.viewport = vp
+	}
+	if s, ok := image.Setting("ColorMode"); ok {
+		if len(s.Args) != 1 {
+			return nil, fmt.Errorf("color mode takes 1 argument, got %d", len(s.Args))
+		}
+		mode, err := parseColorMode(s.Args[0])
+		if err != nil {
+			return nil, err
+		}
+		t.colorMode = mode
+	}
+	if s, ok := image.Setting("Palette"); ok {
+		p, err := parsePalette(s.Args)
+		if err != nil {
+			return nil, err
+		}
+		t.palette = p
+	}
+	for _, s := range image.Settings {
+		switch s.Name {
+		case "Viewport", "ColorMode", "Palette":
+		default:
+			t.unknown = append(t.unknown, s)
+		}
+	}
+	return t, nil
+}
+
+func NewTextureEquation(rng eqt.Rand) *textureEquation {
+	opNodeCount := rng.Intn(100) + 1
+
+	t := &textureEquation{viewport: defaultViewport}
+	t.r = randomEquation(opNodeCount, rng)
+	t.g = randomEquation(opNodeCount, rng)
+	t.b = randomEquation(opNodeCount, rng)
 
 	return t
 }
 
-func (t *textureEquation) pickRandomColor() eqt.BaseNode {
-	n := rand.Intn(3)
+func (t *textureEquation) pickRandomColor(rng eqt.Rand) eqt.BaseNode {
+	n := rng.Intn(len(t.channels()))
 	switch n {
 	case 0:
 		return t.r
@@ -144,111 +352,447 @@ func (t *textureEquation) pickRandomColor() eqt.BaseNode {
 		return t.g
 	case 2:
 		return t.b
+	case 3:
+		return t.a
 	}
 	panic("pick random failed")
 }
 
+// channels returns the trees of t, including the alpha channel if it has
+// one.
+func (t *textureEquation) channels() []*eqt.BaseNode {
+	if t.a != nil {
+		return []*eqt.BaseNode{&t.r, &t.g, &t.b, &t.a}
+	}
+	return []*eqt.BaseNode{&t.r, &t.g, &t.b}
+}
+
 func copyTextureEquation(t *textureEquation) *textureEquation {
-	result := &textureEquation{eqt.CopyTree(t.r), eqt.CopyTree(t.g), eqt.CopyTree(t.b)}
+	result := t.copySettings()
+	result.r, result.g, result.b = eqt.CopyTree(t.r), eqt.CopyTree(t.g), eqt.CopyTree(t.b)
+	result.a = eqt.CopyTree(t.a)
 	return result
 }
 
-func randomEquation(opNodeCount int) eqt.BaseNode {
+// hash identifies t by its settings and the hashes of its channel trees, so
+// equations differing only in the order of commutative operands collide.
+func (t *textureEquation) hash() uint64 {
+	h := fnv.New64a()
+	for _, s := range t.settings() {
+		h.Write([]byte(s.String()))
+	}
+	for _, channel := range t.channels() {
+		binary.Write(h, binary.LittleEndian, eqt.Hash(*channel))
+	}
+	return h.Sum64()
+}
+
+// copySettings returns an equation with the settings of t and no trees.
+func (t *textureEquation) copySettings() *textureEquation {
+	return &textureEquation{viewport: t.viewport, colorMode: t.colorMode, palette: t.palette, unknown: t.unknown}
+}
+
+func randomEquation(opNodeCount int, rng eqt.Rand) eqt.BaseNode {
 	if opNodeCount < 1 {
 		return nil
 	}
 
-	node := eqt.RandomOpNode()
+	node := eqt.RandomOpNode(rng)
 
 	for i := 1; i < opNodeCount; i++ {
-		node.AddRandomNode(eqt.RandomOpNode())
+		node.AddRandomNode(eqt.RandomOpNode(rng), rng)
 	}
 
-	for node.AddLeafNode(eqt.RandomLeafNode()) {
+	for node.AddLeafNode(eqt.RandomLeafNode(rng)) {
 	}
 
 	return node
 }
 
-func (t *textureEquation) mutate() {
-	n := rand.Intn(3)
+func (t *textureEquation) mutate(rng eqt.Rand) {
+	if t.colorMode == colorPalette && rng.Intn(4) == 0 {
+		t.palette = t.palette.mutate(rng)
+		return
+	}
+
+	n := rng.Intn(len(t.channels()))
 	switch n {
 	case 0:
-		node := eqt.PickRandomNode(t.r)
-		mutatedNode := eqt.Mutate(node)
+		node := eqt.PickRandomNode(t.r, rng)
+		mutatedNode := eqt.Mutate(node, rng)
 		if node == t.r {
 			t.r = mutatedNode
 		}
 	case 1:
-		node := eqt.PickRandomNode(t.g)
-		mutatedNode := eqt.Mutate(node)
+		node := eqt.PickRandomNode(t.g, rng)
+		mutatedNode := eqt.Mutate(node, rng)
 		if node == t.g {
 			t.g = mutatedNode
 		}
 	case 2:
-		node := eqt.PickRandomNode(t.b)
-		mutatedNode := eqt.Mutate(node)
+		node := eqt.PickRandomNode(t.b, rng)
+		mutatedNode := eqt.Mutate(node, rng)
 		if node == t.b {
 			t.b = mutatedNode
 		}
+	case 3:
+		node := eqt.PickRandomNode(t.a, rng)
+		mutatedNode := eqt.Mutate(node, rng)
+		if node == t.a {
+			t.a = mutatedNode
+		}
 	}
 }
 
 func generateTexture(t *textureEquation, width, height int) *ebiten.Image {
-	texture := ebiten.NewImage(width, height)
+	return ebiten.NewImageFromImage(renderTexture(t, t.viewport, previewOptions, width, height))
+}
 
-	for y := 0; y < height; y++ {
-		fy := float32(y)/float32(height)*2 - 1
-		for x := 0; x < width; x++ {
-			fx := float32(x)/float32(width)*2 - 1
+// ranges bounds the values of the channel trees over the viewport, in the
+// order of channels.
+func (t *textureEquation) ranges() []eqt.Interval {
+	x, y := t.viewport.bounds()
+	ranges := make([]eqt.Interval, 0, 4)
+	for i, channel := range t.channels() {
+		if i == 1 {
+			// The green channel is evaluated with x and y swapped.
+			ranges = append(ranges, eqt.Range(*channel, y, x))
+		} else {
+			ranges = append(ranges, eqt.Range(*channel, x, y))
+		}
+	}
+	return ranges
+}
 
-			r := t.r.Eval(fx, fy)*255 + 127
-			g := t.g.Eval(fy, fx)*255 + 127
-			b := t.b.Eval(fx, fy)*255 + 127
-			a := 255
+// flatWidth is the variation of a channel value under which it shows as a
+// single color.
+const flatWidth = 1.0 / 512
 
-			pixel := &color.RGBA{uint8(r), uint8(g), uint8(b), uint8(a)}
-			texture.Set(x, y, pixel)
+// flat reports whether every channel that is rendered provably varies by
+// less than flatWidth over the viewport.
+func (t *textureEquation) flat() bool {
+	for i, r := range t.ranges() {
+		if t.colorMode == colorPalette && (i == 1 || i == 2) {
+			continue
+		}
+		if r.NaN || r.Width() >= flatWidth {
+			return false
 		}
 	}
+	return true
+}
 
-	return texture
+// program compiles the channel trees into one DAG with a root per channel,
+// alpha last, so subexpressions shared by several channels are evaluated
+// once. The green tree is compiled with x and y swapped, as it is evaluated
+// at y, x. In colorPalette only the first tree and the alpha channel are
+// compiled, green and blue are 0.
+func (t *textureEquation) program() *eqt.DAG {
+	d := eqt.NewDAG(t.r)
+	if t.colorMode == colorPalette {
+		d.Add(eqt.NewOpConstant(0))
+		d.Add(eqt.NewOpConstant(0))
+	} else {
+		d.Add(eqt.SwapXY(t.g))
+		d.Add(t.b)
+	}
+	if t.a != nil {
+		d.Add(t.a)
+	}
+	return d
 }
 
 func exportTextureEquation(t *textureEquation) {
 	timestamp := time.Now().UnixMilli()
 	filename := fmt.Sprintf("%d.eqt", timestamp)
 
+	if err := writeTextureEquation(filename, t); err != nil {
+		panic(err)
+	}
+}
+
+// readTextureEquation loads an .eqt file.
+func readTextureEquation(filename string) (*textureEquation, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseTextureEquation(string(bytes))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return t, nil
+}
+
+// parseTextureEquation reads an equation in the prefix or the infix syntax.
+// The prefix parser panics with a *parser.SyntaxError on malformed input,
+// which is returned as the error. Any other panic is a bug and is not caught.
+func parseTextureEquation(src string) (t *textureEquation, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxError, ok := r.(*parser.SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			t, err = nil, syntaxError
+		}
+	}()
+	var node eqt.BaseNode
+	if parser.IsInfix(src) {
+		if node, err = parser.ParseInfix(src); err != nil {
+			return nil, err
+		}
+	} else {
+		node = parser.Parse(parser.Lex(src))
+	}
+	return textureEquationFromImage(node)
+}
+
+// formatTextureEquation returns the canonical text of t: the settings that
+// differ from the defaults, then one channel per line, ending with a newline.
+func formatTextureEquation(t *textureEquation, infix bool) string {
+	if infix {
+		return t.infix() + "\n"
+	}
+	return t.String() + "\n"
+}
+
+func writeTextureEquation(filename string, t *textureEquation) error {
 	file, err := os.Create(filename)
 	if err != nil {
-		panic(err)
+		return err
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprint(file, formatTextureEquation(t, false))
+	return err
+}
+
+func writePNG(filename string, img image.Image) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
 	}
 	defer file.Close()
 
-	fmt.Fprint(file, t.String())
+	return png.Encode(file, img)
+}
+
+// thumbnail is an image generated in the background for a texture. It is
+// dropped if the texture changed equation or size in the meantime.
+type thumbnail struct {
+	texture  *texture
+	equation *textureEquation
+	image    *ebiten.Image
+}
+
+// zoomPass is a pass of the progressive render of the zoom view. Passes of
+// an older render than the current one are dropped.
+type zoomPass struct {
+	render int64
+	image  *image.RGBA
 }
 
 // Game implements ebiten.Game interface.
 type Game struct {
+	layout              gridLayout
+	input               gui.Input
 	texturesChannel     chan *texture
+	thumbnailsChannel   chan thumbnail
 	textures            []*texture
-	button              *gui.Button
+	toolbar             *gui.Screen
+	rejections          *gui.Label
+	evolveOptions       evolveOptions
 	zoomImage           *ebiten.Image
 	zoomTextureEquation *textureEquation
+	zoomPasses          chan zoomPass
+	zoomOptions         renderOptions
+	zoomRender          atomic.Int64
+	panning             bool
+	panX, panY          int
+	inspector           *inspector
+	editor              *editor
+	edited              bool
 }
 
-func NewGame() *Game {
+func NewGame(layout gridLayout, input gui.Input) *Game {
 	texChan := make(chan *texture)
-	textures := make([]*texture, numOfTextures)
-
-	button := gui.NewButton((screenWidth-80)/2, (screenHeight - 40), 80, 30)
+	textures := make([]*texture, layout.count())
 
-	for i := range numOfTextures {
+	for i := range layout.count() {
 		go func(i int) {
-			texChan <- NewTexture(i)
+			texChan <- NewTexture(i, layout)
 		}(i)
 	}
-	return &Game{textures: textures, texturesChannel: texChan, button: button}
+	options := evolveOptions{minDistance: defaultMinDistance, retries: 5, archive: newNoveltyArchive(5, 500), quality: defaultQualityGate}
+	g := &Game{layout: layout, input: input, textures: textures, texturesChannel: texChan, thumbnailsChannel: make(chan thumbnail), zoomPasses: make(chan zoomPass, len(renderPasses)), zoomOptions: previewOptions, evolveOptions: options}
+	g.toolbar = g.newToolbar()
+	g.placeToolbar()
+	return g
+}
+
+// relayout moves every texture to its cell in layout and generates the
+// images again at the new size.
+func (g *Game) relayout(layout gridLayout) {
+	g.layout = layout
+	g.placeToolbar()
+	for _, t := range g.textures {
+		if t != nil && t.place(layout) {
+			g.regenerate(t)
+		}
+	}
+	if g.zoomTextureEquation != nil {
+		g.zoom(g.zoomTextureEquation)
+	}
+	if g.editor != nil {
+		g.editor.layout(layout.screenWidth, layout.screenHeight)
+	}
+}
+
+func (g *Game) regenerate(t *texture) {
+	eq, width, height := t.equation, t.width, t.height
+	go func() {
+		g.thumbnailsChannel <- thumbnail{t, eq, generateTexture(eq, width, height)}
+	}()
+}
+
+func (g *Game) zoom(eq *textureEquation) {
+	if eq != g.zoomTextureEquation {
+		g.zoomImage = nil
+	}
+	g.zoomTextureEquation = eq
+	g.renderZoom()
+	if g.inspector != nil && g.inspector.equation != eq {
+		g.inspector = nil
+	}
+	if g.editor != nil && g.editor.equation != eq {
+		g.editor = nil
+	}
+}
+
+// renderZoom starts a progressive render of the zoom view and cancels the
+// previous one. The equation is copied so it can be edited meanwhile.
+func (g *Game) renderZoom() {
+	eq := copyTextureEquation(g.zoomTextureEquation)
+	width, height := g.layout.screenWidth, g.layout.screenHeight
+	render := g.zoomRender.Add(1)
+	cancelled := func() bool {
+		return g.zoomRender.Load() != render
+	}
+	go renderProgressive(eq, eq.viewport, g.zoomOptions, width, height, cancelled, func(texture *image.RGBA) {
+		g.zoomPasses <- zoomPass{render, texture}
+	})
+}
+
+// closeZoom goes back to the grid, generating the thumbnails of the zoomed
+// equation again if it changed.
+func (g *Game) closeZoom() {
+	if g.edited {
+		for _, t := range g.textures {
+			if t != nil && t.equation == g.zoomTextureEquation {
+				g.regenerate(t)
+			}
+		}
+		g.edited = false
+	}
+	g.zoomRender.Add(1)
+	g.zoomImage = nil
+	g.zoomTextureEquation = nil
+	g.inspector = nil
+	g.editor = nil
+	g.panning = false
+}
+
+// navigate pans the zoom view by dragging, zooms it with the mouse wheel and
+// rotates it with the bracket keys. R resets the viewport, M switches to the
+// next color mode and P picks a random palette. A adds or removes a random
+// alpha channel. T switches the tonemap of the zoom view only.
+func (g *Game) navigate() {
+	vp := g.zoomTextureEquation.viewport
+	width, height := g.layout.screenWidth, g.layout.screenHeight
+	mx, my := g.input.CursorPosition()
+	overEditor := g.editor != nil && g.editor.screen.Contains(mx, my)
+
+	if g.input.IsMouseButtonJustPressed(ebiten.MouseButton0) && !overEditor {
+		g.panning = true
+		g.panX, g.panY = mx, my
+	}
+	if !g.input.IsMouseButtonPressed(ebiten.MouseButton0) {
+		g.panning = false
+	}
+	if g.panning && (mx != g.panX || my != g.panY) {
+		vp.pan(mx-g.panX, my-g.panY, width, height)
+		g.panX, g.panY = mx, my
+	}
+
+	if _, wheel := g.input.Wheel(); wheel != 0 && !overEditor && g.inspector == nil {
+		vp.zoomAt(mx, my, width, height, float32(math.Pow(0.9, wheel)))
+	}
+
+	if g.input.IsKeyJustPressed(ebiten.KeyBracketLeft) {
+		vp.rotate(-math.Pi / 12)
+	}
+	if g.input.IsKeyJustPressed(ebiten.KeyBracketRight) {
+		vp.rotate(math.Pi / 12)
+	}
+	if g.input.IsKeyJustPressed(ebiten.KeyR) {
+		vp = defaultViewport
+	}
+
+	eq := g.zoomTextureEquation
+	if g.input.IsKeyJustPressed(ebiten.KeyM) {
+		eq.colorMode = eq.colorMode.next()
+		g.edited = true
+		g.renderZoom()
+	}
+	if g.input.IsKeyJustPressed(ebiten.KeyP) && eq.colorMode == colorPalette {
+		eq.palette = randomPalette(eqt.DefaultRand)
+		g.edited = true
+		g.renderZoom()
+	}
+	if g.input.IsKeyJustPressed(ebiten.KeyA) {
+		if eq.a == nil {
+			eq.a = randomEquation(eqt.DefaultRand.Intn(100)+1, eqt.DefaultRand)
+		} else {
+			eq.a = nil
+		}
+		// The editor and the inspector list the channels, start them over.
+		if g.editor != nil {
+			g.editor = nil
+			g.toggleEditor()
+		}
+		if g.inspector != nil {
+			g.inspector = newInspector(eq)
+		}
+		g.edited = true
+		g.renderZoom()
+	}
+	if g.input.IsKeyJustPressed(ebiten.KeyT) {
+		g.zoomOptions.tonemap.mode = g.zoomOptions.tonemap.mode.next()
+		g.renderZoom()
+	}
+
+	if vp != g.zoomTextureEquation.viewport {
+		g.zoomTextureEquation.viewport = vp
+		g.edited = true
+		g.renderZoom()
+	}
+}
+
+// toggleEditor opens or closes the tree editor of the zoomed equation. Edits
+// change the equation in place and render the zoom view again.
+func (g *Game) toggleEditor() {
+	if g.editor != nil {
+		g.editor = nil
+		return
+	}
+	eq := g.zoomTextureEquation
+	g.editor = newEditor(eq, func() {
+		g.zoom(eq)
+		g.edited = true
+	})
+	g.editor.layout(g.layout.screenWidth, g.layout.screenHeight)
+	g.inspector = nil
 }
 
 // Update proceeds the game state.
@@ -256,29 +800,54 @@ func NewGame() *Game {
 func (g *Game) Update() error {
 	// Write your game's logical update.
 
-	if inpututil.IsMouseButtonJustPressed(ebiten.MouseButton2) {
-		if g.zoomImage != nil {
-			g.zoomImage = nil
+	if g.input.IsMouseButtonJustPressed(ebiten.MouseButton2) {
+		if g.zoomTextureEquation != nil {
+			g.closeZoom()
 		} else {
-			mx, my := ebiten.CursorPosition()
+			mx, my := g.input.CursorPosition()
 			for _, t := range g.textures {
-				if mx >= t.x && mx <= t.x+int(textureWidth) && my >= t.y && my <= t.y+int(textureHeight) {
-					g.zoomImage = generateTexture(t.equation, screenWidth, screenHeight)
-					g.zoomTextureEquation = t.equation
+				if t != nil && t.contains(mx, my) {
+					g.zoom(t.equation)
 				}
 			}
 		}
 	}
 
 	if g.zoomTextureEquation != nil {
-		if inpututil.IsKeyJustPressed(ebiten.KeySpace) {
+		if g.editor != nil {
+			g.editor.update(g.input)
+			if g.editor.screen.CapturesKeyboard() {
+				return nil
+			}
+		}
+		if g.input.IsKeyJustPressed(ebiten.KeyE) {
+			g.toggleEditor()
+		}
+		if g.input.IsKeyJustPressed(ebiten.KeyI) {
+			g.editor = nil
+			if g.inspector == nil {
+				g.inspector = newInspector(g.zoomTextureEquation)
+			} else {
+				g.inspector = nil
+			}
+		}
+		if g.inspector != nil {
+			g.inspector.update(g.input, g.layout.screenHeight)
+		}
+		g.navigate()
+		if g.input.IsKeyJustPressed(ebiten.KeySpace) {
 			exportTextureEquation(g.zoomTextureEquation)
 		}
 		return nil
 	}
 
+	g.toolbar.Update(g.input)
+	if g.toolbar.CapturesKeyboard() {
+		return nil
+	}
+
 	keySpace := ebiten.KeySpace
-	if inpututil.IsKeyJustPressed(keySpace) {
+	if g.input.IsKeyJustPressed(keySpace) {
 		for _, tex := range g.textures {
 			go func() {
 				if tex != nil && tex.selected {
@@ -289,29 +858,13 @@ func (g *Game) Update() error {
 		}
 	}
 
-	if ebiten.IsKeyPressed(ebiten.KeyEscape) {
+	if g.input.IsKeyPressed(ebiten.KeyEscape) {
 		return ebiten.Termination
 	}
 
-	if g.button.IsClicked() {
-		selectedEquations := make([]*textureEquation, 0)
-		for _, t := range g.textures {
-			if t != nil && t.selected {
-				selectedEquations = append(selectedEquations, t.equation)
-			}
-		}
-		if len(selectedEquations) > 0 {
-			eqs := evolve(selectedEquations)
-			for i := range g.textures {
-				g.textures[i].applyEquation(eqs[i])
-				g.textures[i].selected = false
-			}
-		}
-	}
-
 	for _, t := range g.textures {
 		if t != nil {
-			t.update()
+			t.update(g.input)
 		}
 	}
 
@@ -326,12 +879,39 @@ func (g *Game) Draw(screen *ebiten.Image) {
 	case tex, ok := <-g.texturesChannel:
 		if ok {
 			g.textures[tex.index] = tex
+			if tex.place(g.layout) {
+				g.regenerate(tex)
+			}
+		}
+	case thumb := <-g.thumbnailsChannel:
+		t := thumb.texture
+		width, height := thumb.image.Bounds().Dx(), thumb.image.Bounds().Dy()
+		if t.equation == thumb.equation && t.width == width && t.height == height {
+			t.image = thumb.image
+		}
+	case pass := <-g.zoomPasses:
+		if g.zoomTextureEquation != nil && pass.render == g.zoomRender.Load() {
+			if g.zoomImage != nil {
+				g.zoomImage.Deallocate()
+			}
+			g.zoomImage = ebiten.NewImageFromImage(pass.image)
 		}
 	default:
 	}
 
-	if g.zoomImage != nil {
-		screen.DrawImage(g.zoomImage, nil)
+	if g.zoomTextureEquation != nil {
+		if g.zoomImage != nil {
+			if g.zoomTextureEquation.a != nil {
+				screen.DrawImage(checkerboard(g.layout.screenWidth, g.layout.screenHeight), nil)
+			}
+			screen.DrawImage(g.zoomImage, nil)
+		}
+		if g.editor != nil {
+			g.editor.screen.Draw(screen)
+		}
+		if g.inspector != nil {
+			g.inspector.draw(screen)
+		}
 		return
 	}
 
@@ -340,35 +920,62 @@ func (g *Game) Draw(screen *ebiten.Image) {
 			tex.draw(screen)
 		}
 	}
-	g.button.Draw(screen)
+	g.toolbar.Draw(screen)
 }
 
 // Layout takes the outside size (e.g., the window size) and returns the (logical) screen size.
-// If you don't have to adjust the screen size with the outside size, just return a fixed size.
+// The grid is laid out again whenever the window is resized.
 func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return screenWidth, screenHeight
+	width, height := outsideWidth/windowScale, outsideHeight/windowScale
+	if width != g.layout.screenWidth || height != g.layout.screenHeight {
+		layout := newGridLayout(width, height, g.layout.rows, g.layout.cols)
+		if layout != g.layout {
+			g.relayout(layout)
+		}
+	}
+	return g.layout.screenWidth, g.layout.screenHeight
 }
 
 func main() {
-	ebiten.SetWindowSize(screenWidth*2, screenHeight*2)
+	if len(os.Args) > 1 {
+		commands := map[string]func([]string) error{
+			"evolve": runEvolve,
+			"render": runRender,
+			"graph":  runGraph,
+			"fmt":    runFmt,
+		}
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+	}
+
+	width := flag.Int("width", 1920/4, "logical screen width, the window is twice as large")
+	height := flag.Int("height", 1080/4, "logical screen height, the window is twice as large")
+	rows := flag.Int("rows", 3, "rows of the texture grid")
+	cols := flag.Int("cols", 3, "columns of the texture grid")
+	flag.Parse()
+	if *rows < 1 || *cols < 1 {
+		log.Fatal("rows and cols must be positive")
+	}
+
+	layout := newGridLayout(*width, *height, *rows, *cols)
+	ebiten.SetWindowSize(layout.screenWidth*windowScale, layout.screenHeight*windowScale)
+	ebiten.SetWindowResizingMode(ebiten.WindowResizingModeEnabled)
 	ebiten.SetWindowTitle("TextureGen")
 
 	rand.Seed(time.Now().UnixNano())
 
-	game := NewGame()
+	game := NewGame(layout, gui.EbitenInput{})
 
-	args := os.Args
-	if len(args) > 1 {
-		bytes, err := os.ReadFile(args[1])
+	if flag.NArg() > 0 {
+		texEq, err := readTextureEquation(flag.Arg(0))
 		if err != nil {
-			panic("read file error")
+			log.Fatal(err)
 		}
-
-		s := string(bytes)
-		tokens := parser.Lex(s)
-		imageTree := parser.Parse(tokens)
-		texEq := &textureEquation{imageTree.GetChildren()[0], imageTree.GetChildren()[1], imageTree.GetChildren()[2]}
-		game.zoomImage = generateTexture(texEq, screenWidth, screenHeight)
+		game.zoom(texEq)
 	}
 
 	if err := ebiten.RunGame(game); err != nil {
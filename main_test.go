@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hajimehoshi/ebiten/v2"
+	"github.com/toantht/texturegen/gui"
+	"github.com/toantht/texturegen/parser"
+)
+
+// newTestGame returns a game whose textures are all placed, driven by the
+// returned script.
+func newTestGame(t *testing.T) (*Game, *gui.ScriptedInput) {
+	t.Helper()
+	input := gui.NewScriptedInput()
+	g := NewGame(newGridLayout(480, 270, 3, 3), input)
+	for range g.textures {
+		tex := <-g.texturesChannel
+		g.textures[tex.index] = tex
+		tex.place(g.layout)
+	}
+	return g, input
+}
+
+// play updates g once for every frame left in the script.
+func play(g *Game, input *gui.ScriptedInput) {
+	for input.Step() {
+		g.Update()
+	}
+}
+
+func center(tex *texture) (int, int) {
+	return tex.x + tex.width/2, tex.y + tex.height/2
+}
+
+func TestClickTogglesSelection(t *testing.T) {
+	g, input := newTestGame(t)
+	tex := g.textures[4]
+	x, y := center(tex)
+
+	input.Click(ebiten.MouseButton0, x, y)
+	play(g, input)
+	for i, other := range g.textures {
+		if other.selected != (other == tex) {
+			t.Errorf("after one click, texture %d selected = %v", i, other.selected)
+		}
+	}
+
+	input.Click(ebiten.MouseButton0, x, y)
+	play(g, input)
+	if tex.selected {
+		t.Error("a second click did not deselect the texture")
+	}
+}
+
+func TestEvolveButtonReplacesTextures(t *testing.T) {
+	g, input := newTestGame(t)
+	before := make([]*textureEquation, len(g.textures))
+	for i, tex := range g.textures {
+		before[i] = tex.equation
+	}
+	for _, tex := range g.textures[:2] {
+		x, y := center(tex)
+		input.Click(ebiten.MouseButton0, x, y)
+	}
+
+	var evolve *gui.Button
+	for _, w := range g.toolbar.Root.(*gui.Panel).Children() {
+		if b, ok := w.(*gui.Button); ok && b.Label == "Evolve" {
+			evolve = b
+		}
+	}
+	if evolve == nil {
+		t.Fatal("the toolbar has no Evolve button")
+	}
+	bounds := evolve.Bounds()
+	input.Click(ebiten.MouseButton0, bounds.Min.X+bounds.Dx()/2, bounds.Min.Y+bounds.Dy()/2)
+	play(g, input)
+
+	for i, tex := range g.textures {
+		if tex.selected {
+			t.Errorf("texture %d is still selected after evolving", i)
+		}
+		if tex.equation == before[i] {
+			t.Errorf("texture %d kept its equation", i)
+		}
+	}
+}
+
+func TestZoomInAndOut(t *testing.T) {
+	g, input := newTestGame(t)
+	tex := g.textures[0]
+	x, y := center(tex)
+
+	input.Click(ebiten.MouseButton2, x, y)
+	play(g, input)
+	if g.zoomTextureEquation != tex.equation {
+		t.Fatal("a right click did not zoom on the texture")
+	}
+
+	scale := g.zoomTextureEquation.viewport.scale
+	input.Push(gui.InputFrame{X: x, Y: y, WheelY: 1})
+	play(g, input)
+	if got := g.zoomTextureEquation.viewport.scale; got >= scale {
+		t.Errorf("scrolling up changed the scale from %v to %v, want smaller", scale, got)
+	}
+	scale = g.zoomTextureEquation.viewport.scale
+	input.Push(gui.InputFrame{X: x, Y: y, WheelY: -2})
+	play(g, input)
+	if got := g.zoomTextureEquation.viewport.scale; got <= scale {
+		t.Errorf("scrolling down changed the scale from %v to %v, want larger", scale, got)
+	}
+
+	input.Click(ebiten.MouseButton2, x, y)
+	play(g, input)
+	if g.zoomTextureEquation != nil || g.zoomImage != nil {
+		t.Error("a second right click did not close the zoom view")
+	}
+}
+
+func TestSpaceExportsZoomedEquation(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	g, input := newTestGame(t)
+	tex := g.textures[2]
+	x, y := center(tex)
+	input.Click(ebiten.MouseButton2, x, y)
+	input.PressKey(ebiten.KeySpace)
+	play(g, input)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eqt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("exported %d files, want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != tex.equation.String() {
+		t.Errorf("exported\n%s\nwant\n%s", got, tex.equation)
+	}
+}
+
+func TestReadTextureEquationReturnsSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		src          string
+		line, column int
+	}{
+		{"(EquationImage X Y)", 1, 20},
+		{"(EquationImage\nX Y Foo)", 2, 5},
+		{"r = x\ng = y +\nb = x", 2, 8},
+	}
+	for _, test := range tests {
+		filename := filepath.Join(t.TempDir(), "equation.eqt")
+		if err := os.WriteFile(filename, []byte(test.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := readTextureEquation(filename)
+		var syntaxError *parser.SyntaxError
+		if !errors.As(err, &syntaxError) || syntaxError.Line != test.line || syntaxError.Column != test.column {
+			t.Errorf("%q: got %v, want a syntax error at line %d, column %d", test.src, err, test.line, test.column)
+		}
+	}
+}
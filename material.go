@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// heightSource is what the height map of a material is taken from: the
+// luminance of the rendered texture or the value of one channel tree.
+type heightSource int
+
+const (
+	heightLuminance heightSource = iota
+	heightRed
+	heightGreen
+	heightBlue
+	heightAlpha
+)
+
+var heightSources = []heightSource{heightLuminance, heightRed, heightGreen, heightBlue, heightAlpha}
+
+func (s heightSource) String() string {
+	switch s {
+	case heightLuminance:
+		return "luminance"
+	case heightRed:
+		return "r"
+	case heightGreen:
+		return "g"
+	case heightBlue:
+		return "b"
+	case heightAlpha:
+		return "a"
+	}
+	return "unknown"
+}
+
+func parseHeightSource(name string) (heightSource, error) {
+	for _, s := range heightSources {
+		if s.String() == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown height source %q", name)
+}
+
+// materialOptions control how the maps are derived from the height map.
+// strength is the slope given by a height difference of 1 between two
+// neighbouring pixels. aoRadius is the distance in pixels searched for
+// occluders.
+type materialOptions struct {
+	height   heightSource
+	strength float32
+	aoRadius int
+}
+
+var defaultMaterialOptions = materialOptions{height: heightLuminance, strength: 8, aoRadius: 8}
+
+// material is a set of maps for physically based rendering. The normal map
+// is in tangent space with green pointing up, as OpenGL expects.
+type material struct {
+	albedo    *floatImage
+	height    *floatImage
+	normal    *floatImage
+	ao        *floatImage
+	roughness *floatImage
+}
+
+// materialMap is a map of a material and the suffix of its file name.
+type materialMap struct {
+	name  string
+	image *floatImage
+}
+
+func (m *material) maps() []materialMap {
+	return []materialMap{
+		{"albedo", m.albedo},
+		{"height", m.height},
+		{"normal", m.normal},
+		{"ao", m.ao},
+		{"roughness", m.roughness},
+	}
+}
+
+// heightField is a grid of heights in [0,1]. Reads outside of the grid are
+// clamped to its edges.
+type heightField struct {
+	width, height int
+	values        []float32
+}
+
+func (f heightField) at(x, y int) float32 {
+	x = min(max(x, 0), f.width-1)
+	y = min(max(y, 0), f.height-1)
+	return f.values[y*f.width+x]
+}
+
+func renderMaterial(t *textureEquation, vp viewport, opts renderOptions, m materialOptions, width, height int) *material {
+	albedo := renderFloat(t, vp, opts, width, height)
+	field := heightField{width, height, make([]float32, width*height)}
+	if m.height == heightLuminance {
+		for i, c := range albedo.pix {
+			field.values[i] = 0.2126*c[0] + 0.7152*c[1] + 0.0722*c[2]
+		}
+	} else {
+		r := newRenderer(t, vp, opts, width, height)
+		channel := int(m.height - heightRed)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				field.values[y*width+x] = r.channelAt(float32(x), float32(y), channel)
+			}
+		}
+	}
+
+	result := &material{
+		albedo:    albedo,
+		height:    newFloatImage(width, height),
+		normal:    newFloatImage(width, height),
+		ao:        newFloatImage(width, height),
+		roughness: newFloatImage(width, height),
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			result.height.set(x, y, gray(field.at(x, y)))
+			result.normal.set(x, y, field.normalAt(x, y, m.strength))
+			result.ao.set(x, y, gray(field.occlusionAt(x, y, m.strength, m.aoRadius)))
+			result.roughness.set(x, y, gray(field.roughnessAt(x, y)))
+		}
+	}
+	return result
+}
+
+func gray(v float32) floatColor {
+	v = clamp01(v)
+	return floatColor{v, v, v, 1}
+}
+
+// normalAt returns the normal from the central differences of the heights
+// around x, y, mapped from [-1,1] to [0,1].
+func (f heightField) normalAt(x, y int, strength float32) floatColor {
+	dx := (f.at(x+1, y) - f.at(x-1, y)) / 2 * strength
+	dy := (f.at(x, y+1) - f.at(x, y-1)) / 2 * strength
+	// Rows go down the image but the green channel points up.
+	nx, ny, nz := -dx, dy, float32(1)
+	length := float32(math.Sqrt(float64(nx*nx + ny*ny + nz*nz)))
+	return floatColor{nx/length*0.5 + 0.5, ny/length*0.5 + 0.5, nz/length*0.5 + 0.5, 1}
+}
+
+// occlusionAt looks for the highest horizon in eight directions around x, y
+// and returns 1 for an unoccluded pixel, less for a pixel in a cavity.
+func (f heightField) occlusionAt(x, y int, strength float32, radius int) float32 {
+	directions := [][2]int{{1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}, {0, -1}, {1, -1}}
+	h := f.at(x, y)
+	occlusion := float32(0)
+	for _, d := range directions {
+		step := float32(math.Hypot(float64(d[0]), float64(d[1])))
+		horizon := float32(0)
+		for s := 1; s <= radius; s++ {
+			slope := (f.at(x+d[0]*s, y+d[1]*s) - h) * strength / (step * float32(s))
+			horizon = max(horizon, slope)
+		}
+		// The sine of the elevation of the horizon.
+		occlusion += horizon / float32(math.Sqrt(float64(1+horizon*horizon)))
+	}
+	return 1 - occlusion/float32(len(directions))
+}
+
+// roughnessAt is rougher where the height changes more around x, y.
+func (f heightField) roughnessAt(x, y int) float32 {
+	var sum, sumSquares float32
+	for ny := y - 1; ny <= y+1; ny++ {
+		for nx := x - 1; nx <= x+1; nx++ {
+			v := f.at(nx, ny)
+			sum += v
+			sumSquares += v * v
+		}
+	}
+	mean := sum / 9
+	deviation := float32(math.Sqrt(float64(max(sumSquares/9-mean*mean, 0))))
+	return 0.4 + 4*deviation
+}
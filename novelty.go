@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+const defaultMinDistance = 0.04
+
+// descriptorSize is the width and height of the render used as a behavior
+// descriptor.
+const descriptorSize = 8
+
+// behavior describes what an equation looks like as a downsampled render with
+// every channel scaled to [0,1].
+type behavior []float32
+
+func describe(t *textureEquation) behavior {
+	img := renderTexture(t, t.viewport, renderOptions{pointSampling, defaultTonemap}, descriptorSize, descriptorSize)
+	b := make(behavior, 0, descriptorSize*descriptorSize*3)
+	for i := 0; i < len(img.Pix); i += 4 {
+		b = append(b, float32(img.Pix[i])/255, float32(img.Pix[i+1])/255, float32(img.Pix[i+2])/255)
+	}
+	return b
+}
+
+// distance is the root mean square difference between two descriptors.
+func (b behavior) distance(other behavior) float64 {
+	sum := 0.0
+	for i := range b {
+		d := float64(b[i] - other[i])
+		sum += d * d
+	}
+	return math.Sqrt(sum / float64(len(b)))
+}
+
+type archiveEntry struct {
+	equation *textureEquation
+	behavior behavior
+}
+
+// noveltyArchive remembers the behaviors seen so far. Novelty is the mean
+// distance to the k nearest behaviors in the archive and the population.
+type noveltyArchive struct {
+	entries []archiveEntry
+	k       int
+	limit   int
+}
+
+func newNoveltyArchive(k, limit int) *noveltyArchive {
+	return &noveltyArchive{k: k, limit: limit}
+}
+
+// add stores an entry, dropping the oldest one once the archive is full.
+func (a *noveltyArchive) add(t *textureEquation, b behavior) {
+	a.entries = append(a.entries, archiveEntry{t, b})
+	if a.limit > 0 && len(a.entries) > a.limit {
+		a.entries = a.entries[len(a.entries)-a.limit:]
+	}
+}
+
+// novelty scores b against the archive and the given population. Entries of
+// population identical to b (b itself) are skipped.
+func (a *noveltyArchive) novelty(b behavior, population []behavior) float64 {
+	distances := make([]float64, 0, len(a.entries)+len(population))
+	for _, entry := range a.entries {
+		distances = append(distances, b.distance(entry.behavior))
+	}
+	for _, other := range population {
+		if &other[0] == &b[0] {
+			continue
+		}
+		distances = append(distances, b.distance(other))
+	}
+	if len(distances) == 0 {
+		return math.Inf(1)
+	}
+
+	sort.Float64s(distances)
+	k := min(a.k, len(distances))
+	sum := 0.0
+	for _, d := range distances[:k] {
+		sum += d
+	}
+	return sum / float64(k)
+}
+
+// tooSimilar reports whether b lies within minDistance of any of the given
+// behaviors or of an archived one.
+func tooSimilar(b behavior, behaviors []behavior, archive *noveltyArchive, minDistance float64) bool {
+	for _, other := range behaviors {
+		if b.distance(other) < minDistance {
+			return true
+		}
+	}
+	if archive != nil {
+		for _, entry := range archive.entries {
+			if b.distance(entry.behavior) < minDistance {
+				return true
+			}
+		}
+	}
+	return false
+}
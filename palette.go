@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	eqt "github.com/toantht/texturegen/equation"
+)
+
+type paletteStop struct {
+	position float32
+	color    [3]float32
+}
+
+// palette is a gradient of colors ordered by position in [0,1]. Palettes
+// are never modified in place, so equations can share them.
+type palette []paletteStop
+
+var grayPalette = palette{
+	{0, [3]float32{0, 0, 0}},
+	{1, [3]float32{1, 1, 1}},
+}
+
+// at returns the color of the gradient at v, interpolating linearly between
+// the two nearest stops.
+func (p palette) at(v float32) floatColor {
+	if len(p) == 0 {
+		p = grayPalette
+	}
+	i := sort.Search(len(p), func(i int) bool { return p[i].position >= v })
+	if i == 0 {
+		return stopColor(p[0].color)
+	}
+	if i == len(p) {
+		return stopColor(p[len(p)-1].color)
+	}
+
+	a, b := p[i-1], p[i]
+	t := float32(0)
+	if b.position > a.position {
+		t = (v - a.position) / (b.position - a.position)
+	}
+	var c [3]float32
+	for j := range c {
+		c[j] = a.color[j] + (b.color[j]-a.color[j])*t
+	}
+	return stopColor(c)
+}
+
+func stopColor(c [3]float32) floatColor {
+	return floatColor{clamp01(c[0]), clamp01(c[1]), clamp01(c[2]), 1}
+}
+
+// randomPalette returns a gradient of 2 to 5 random colors spanning [0,1].
+func randomPalette(rng eqt.Rand) palette {
+	n := rng.Intn(4) + 2
+	p := make(palette, n)
+	for i := range p {
+		p[i].position = rng.Float32()
+		p[i].color = [3]float32{rng.Float32(), rng.Float32(), rng.Float32()}
+	}
+	p[0].position, p[n-1].position = 0, 1
+	sort.Slice(p, func(i, j int) bool { return p[i].position < p[j].position })
+	return p
+}
+
+// mutate returns a copy of p with one stop moved or recolored.
+func (p palette) mutate(rng eqt.Rand) palette {
+	if len(p) == 0 {
+		p = grayPalette
+	}
+	result := append(palette{}, p...)
+	i := rng.Intn(len(result))
+	if i > 0 && i < len(result)-1 && rng.Intn(2) == 0 {
+		low, high := result[i-1].position, result[i+1].position
+		result[i].position = low + (high-low)*rng.Float32()
+	} else {
+		result[i].color[rng.Intn(3)] = rng.Float32()
+	}
+	return result
+}
+
+// setting is the palette as stored in .eqt files, four numbers per stop:
+// the position and the red, green and blue components.
+func (p palette) setting() eqt.Setting {
+	args := make([]string, 0, len(p)*4)
+	for _, stop := range p {
+		args = append(args, formatArg(stop.position))
+		for _, c := range stop.color {
+			args = append(args, formatArg(c))
+		}
+	}
+	return eqt.Setting{Name: "Palette", Args: args}
+}
+
+func parsePalette(args []string) (palette, error) {
+	if len(args) < 8 || len(args)%4 != 0 {
+		return nil, fmt.Errorf("palette takes four numbers for each of at least two stops, got %d", len(args))
+	}
+	values := make([]float32, len(args))
+	for i, arg := range args {
+		value, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return nil, fmt.Errorf("palette: %w", err)
+		}
+		values[i] = float32(value)
+	}
+
+	p := make(palette, len(values)/4)
+	for i := range p {
+		v := values[i*4:]
+		p[i] = paletteStop{v[0], [3]float32{v[1], v[2], v[3]}}
+	}
+	sort.SliceStable(p, func(i, j int) bool { return p[i].position < p[j].position })
+	return p, nil
+}
+
+// loadPalette reads a palette file: one stop per line, written as a position
+// and the red, green and blue components, all in [0,1]. Empty lines and
+// lines starting with # are ignored.
+func loadPalette(filename string) (palette, error) {
+	bytes, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, 0)
+	for _, line := range strings.Split(string(bytes), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%s: expected position, red, green and blue in %q", filename, line)
+		}
+		args = append(args, fields...)
+	}
+	return parsePalette(args)
+}
@@ -0,0 +1,438 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	. "github.com/toantht/texturegen/equation"
+)
+
+// The infix syntax writes every channel as an assignment of an expression:
+//
+//	# Comments run to the end of the line, as do // comments.
+//	Viewport(0, 0, 2, 0)
+//	r = x*y + sin(0.5)
+//	g = atan2(y, x); b = cos(x*3)
+//
+// Statements are separated by newlines or semicolons. Expressions use +, -,
+// * and / with the usual precedence, unary minus, parentheses, x, y, the
+// constants pi, e, inf and nan and calls of the operations by name. Names
+// are not case sensitive. A statement without = is a setting of the image.
+
+// IsInfix reports whether src is written in the infix syntax rather than the
+// prefix syntax of .eqt files, which starts with (EquationImage. A leading
+// byte order mark is ignored.
+func IsInfix(src string) bool {
+	for _, line := range strings.Split(strings.TrimPrefix(src, "\uFEFF"), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		return !strings.HasPrefix(line, "(")
+	}
+	return false
+}
+
+type infixKind int
+
+const (
+	infixEOF infixKind = iota
+	infixName
+	infixNumber
+	infixSymbol
+	infixEnd // newline or semicolon
+)
+
+type infixToken struct {
+	kind         infixKind
+	text         string
+	line, column int
+}
+
+func (t infixToken) String() string {
+	switch t.kind {
+	case infixEOF:
+		return "end of input"
+	case infixEnd:
+		return "end of statement"
+	}
+	return strconv.Quote(t.text)
+}
+
+// SyntaxError is an error at a position of the source, counted from 1.
+type SyntaxError struct {
+	Line, Column int
+	Message      string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Message)
+}
+
+func scanInfix(src string) ([]infixToken, error) {
+	tokens := make([]infixToken, 0)
+	runes := []rune(src)
+	line, column := 1, 1
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		token := infixToken{line: line, column: column}
+		switch {
+		case r == '\n' || r == ';':
+			token.kind = infixEnd
+			i++
+		case unicode.IsSpace(r) || r == '\uFEFF':
+			i++
+		case r == '#' || (r == '/' && i+1 < len(runes) && runes[i+1] == '/'):
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case unicode.IsLetter(r) || r == '_':
+			token.kind = infixName
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			token.kind = infixNumber
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for i = j; i < len(runes) && unicode.IsDigit(runes[i]); i++ {
+					}
+				}
+			}
+		case strings.ContainsRune("+-*/(),=", r):
+			token.kind = infixSymbol
+			i++
+		default:
+			return nil, &SyntaxError{line, column, fmt.Sprintf("unexpected %q", r)}
+		}
+
+		// Whitespace and comments are left with the zero kind.
+		if token.kind != infixEOF {
+			token.text = string(runes[start:i])
+			tokens = append(tokens, token)
+		}
+		for _, c := range runes[start:i] {
+			if c == '\n' {
+				line, column = line+1, 1
+			} else {
+				column++
+			}
+		}
+	}
+	return append(tokens, infixToken{kind: infixEOF, line: line, column: column}), nil
+}
+
+type infixParser struct {
+	tokens []infixToken
+	index  int
+}
+
+// peek returns the next token. Reading past the end gives the EOF token.
+func (p *infixParser) peek() infixToken {
+	return p.tokens[min(p.index, len(p.tokens)-1)]
+}
+
+func (p *infixParser) next() infixToken {
+	token := p.peek()
+	p.index++
+	return token
+}
+
+// accept consumes the next token if it is the symbol s.
+func (p *infixParser) accept(s string) bool {
+	if token := p.peek(); token.kind == infixSymbol && token.text == s {
+		p.index++
+		return true
+	}
+	return false
+}
+
+func (p *infixParser) expect(s string) error {
+	if !p.accept(s) {
+		return p.errorf("expected %q, found %s", s, p.peek())
+	}
+	return nil
+}
+
+func (p *infixParser) errorf(format string, args ...any) error {
+	token := p.peek()
+	return &SyntaxError{token.line, token.column, fmt.Sprintf(format, args...)}
+}
+
+// ParseInfix parses an image written in the infix syntax. It needs the r, g
+// and b channels, a is optional.
+func ParseInfix(src string) (*OpImage, error) {
+	tokens, err := scanInfix(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &infixParser{tokens: tokens}
+	image := NewOpImage()
+	channels := map[string]BaseNode{}
+	for {
+		for p.peek().kind == infixEnd {
+			p.next()
+		}
+		if p.peek().kind == infixEOF {
+			break
+		}
+
+		name := p.next()
+		if name.kind != infixName {
+			p.index--
+			return nil, p.errorf("expected a channel or a setting, found %s", name)
+		}
+		if !p.accept("=") {
+			setting, err := p.setting(name.text)
+			if err != nil {
+				return nil, err
+			}
+			image.Settings = append(image.Settings, setting)
+		} else {
+			channel := strings.ToLower(name.text)
+			if !strings.Contains("rgba", channel) || len(channel) != 1 {
+				return nil, &SyntaxError{name.line, name.column, fmt.Sprintf("unknown channel %q, expected r, g, b or a", name.text)}
+			}
+			if _, ok := channels[channel]; ok {
+				return nil, &SyntaxError{name.line, name.column, fmt.Sprintf("channel %s assigned twice", channel)}
+			}
+			node, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			channels[channel] = node
+		}
+		if kind := p.peek().kind; kind != infixEnd && kind != infixEOF {
+			return nil, p.errorf("expected the end of the statement, found %s", p.peek())
+		}
+	}
+
+	children := make([]BaseNode, 0, 4)
+	for _, channel := range []string{"r", "g", "b", "a"} {
+		node, ok := channels[channel]
+		if !ok {
+			if channel == "a" {
+				break
+			}
+			return nil, p.errorf("channel %s is missing", channel)
+		}
+		node.SetParent(image)
+		children = append(children, node)
+	}
+	image.SetChildren(children)
+	return image, nil
+}
+
+// ParseInfixExpression parses a single infix expression.
+func ParseInfixExpression(src string) (BaseNode, error) {
+	tokens, err := scanInfix(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &infixParser{tokens: tokens}
+	for p.peek().kind == infixEnd {
+		p.next()
+	}
+	node, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == infixEnd {
+		p.next()
+	}
+	if p.peek().kind != infixEOF {
+		return nil, p.errorf("unexpected %s", p.peek())
+	}
+	return node, nil
+}
+
+// setting parses the arguments of a setting, numbers or names.
+func (p *infixParser) setting(name string) (Setting, error) {
+	setting := Setting{Name: name, Args: []string{}}
+	if err := p.expect("("); err != nil {
+		return setting, err
+	}
+	for !p.accept(")") {
+		if len(setting.Args) > 0 {
+			if err := p.expect(","); err != nil {
+				return setting, err
+			}
+		}
+		negative := p.accept("-")
+		arg := p.next()
+		if arg.kind != infixNumber && (arg.kind != infixName || negative) {
+			p.index--
+			return setting, p.errorf("expected an argument, found %s", arg)
+		}
+		if negative {
+			arg.text = "-" + arg.text
+		}
+		setting.Args = append(setting.Args, arg.text)
+	}
+	return setting, nil
+}
+
+// expression parses a sum or difference of terms.
+func (p *infixParser) expression() (BaseNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op BaseNode
+		switch {
+		case p.accept("+"):
+			op = NewOpPlus()
+		case p.accept("-"):
+			op = NewOpMinus()
+		default:
+			return left, nil
+		}
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		left = WithChildren(op, left, right)
+	}
+}
+
+// term parses a product or quotient of factors.
+func (p *infixParser) term() (BaseNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var op BaseNode
+		switch {
+		case p.accept("*"):
+			op = NewOpMult()
+		case p.accept("/"):
+			op = NewOpDiv()
+		default:
+			return left, nil
+		}
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		left = WithChildren(op, left, right)
+	}
+}
+
+// unary parses a factor with any number of leading minus signs. A minus
+// directly before a number, inf or nan gives a negative constant, anything
+// else is subtracted from 0.
+func (p *infixParser) unary() (BaseNode, error) {
+	if !p.accept("-") {
+		return p.primary()
+	}
+	if next := p.peek(); next.kind == infixNumber || next.kind == infixName && isFloatName(next.text) {
+		operand, err := p.primary()
+		if err != nil {
+			return nil, err
+		}
+		c := operand.(*OpConstant)
+		c.SetValue(-c.Value())
+		return c, nil
+	}
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return WithChildren(NewOpMinus(), NewOpConstant(0), operand), nil
+}
+
+var infixConstants = map[string]float32{
+	"pi":       math.Pi,
+	"e":        math.E,
+	"inf":      float32(math.Inf(1)),
+	"infinity": float32(math.Inf(1)),
+	"nan":      float32(math.NaN()),
+}
+
+// isFloatName reports whether name is one of the names strconv.ParseFloat
+// accepts for special values.
+func isFloatName(name string) bool {
+	switch strings.ToLower(name) {
+	case "nan", "inf", "infinity":
+		return true
+	}
+	return false
+}
+
+func (p *infixParser) primary() (BaseNode, error) {
+	token := p.next()
+	switch token.kind {
+	case infixNumber:
+		value, err := strconv.ParseFloat(token.text, 32)
+		if err != nil {
+			return nil, &SyntaxError{token.line, token.column, fmt.Sprintf("invalid number %q", token.text)}
+		}
+		return NewOpConstant(float32(value)), nil
+	case infixName:
+		name := strings.ToLower(token.text)
+		if value, ok := infixConstants[name]; ok {
+			return NewOpConstant(value), nil
+		}
+		for _, op := range Ops {
+			if strings.ToLower(op.Name) == name && op.Name != "Constant" {
+				return p.call(op, token)
+			}
+		}
+		return nil, &SyntaxError{token.line, token.column, fmt.Sprintf("unknown name %q", token.text)}
+	case infixSymbol:
+		if token.text == "(" {
+			node, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			return node, p.expect(")")
+		}
+	}
+	p.index--
+	return nil, p.errorf("expected an expression, found %s", token)
+}
+
+// call parses the arguments of op, which are only allowed to be left out
+// for x and y.
+func (p *infixParser) call(op Op, name infixToken) (BaseNode, error) {
+	node := op.New()
+	if op.Arity == 0 {
+		if p.accept("(") {
+			return node, p.expect(")")
+		}
+		return node, nil
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	children := make([]BaseNode, op.Arity)
+	for i := range children {
+		if i > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, &SyntaxError{name.line, name.column, fmt.Sprintf("%s takes %d arguments", strings.ToLower(op.Name), op.Arity)}
+			}
+		}
+		child, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		children[i] = child
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return WithChildren(node, children...), nil
+}
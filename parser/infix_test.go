@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+func TestIsInfix(t *testing.T) {
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"(EquationImage X Y X)", false},
+		{"\uFEFF(EquationImage X Y X)", false},
+		{"# comment\n\n  (EquationImage let s0 = X in s0 Y X)", false},
+		{"r = x\ng = y\nb = x", true},
+		{"\uFEFFr = x; g = y; b = x", true},
+	}
+	for _, test := range tests {
+		if got := IsInfix(test.src); got != test.want {
+			t.Errorf("IsInfix(%q) = %v, want %v", test.src, got, test.want)
+		}
+	}
+	if _, err := ParseInfix("\uFEFFr = x; g = y; b = x"); err != nil {
+		t.Errorf("infix with a byte order mark: %v", err)
+	}
+}
+
+func TestInfixConstants(t *testing.T) {
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"pi", math.Pi},
+		{"e", math.E},
+		{"inf", math.Inf(1)},
+		{"-inf", math.Inf(-1)},
+		{"infinity", math.Inf(1)},
+		{"-Infinity", math.Inf(-1)},
+		{"NaN", math.NaN()},
+	}
+	for _, test := range tests {
+		node, err := ParseInfixExpression(test.src)
+		if err != nil {
+			t.Errorf("%s: %v", test.src, err)
+			continue
+		}
+		if !sameTree(node, NewOpConstant(float32(test.want))) {
+			t.Errorf("%s read as %s, want %v", test.src, node, test.want)
+		}
+	}
+}
+
+// sameTree compares trees node by node, telling constants apart by their
+// bits.
+func sameTree(a, b BaseNode) bool {
+	constantA, okA := a.(*OpConstant)
+	constantB, okB := b.(*OpConstant)
+	if okA || okB {
+		return okA && okB && math.Float32bits(constantA.Value()) == math.Float32bits(constantB.Value())
+	}
+	if Name(a) != Name(b) || len(a.GetChildren()) != len(b.GetChildren()) {
+		return false
+	}
+	for i, child := range a.GetChildren() {
+		if !sameTree(child, b.GetChildren()[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestInfixRoundTrip(t *testing.T) {
+	constant := func(v float64) BaseNode {
+		return NewOpConstant(float32(v))
+	}
+	minus := func(a, b BaseNode) BaseNode {
+		return WithChildren(NewOpMinus(), a, b)
+	}
+	trees := []BaseNode{
+		minus(constant(0), constant(3)),
+		minus(constant(0), constant(-3)),
+		minus(constant(0), minus(constant(0), constant(3))),
+		minus(constant(math.Copysign(0, -1)), NewOpX()),
+		WithChildren(NewOpMult(), minus(constant(0), constant(2)), NewOpY()),
+		constant(math.NaN()),
+		constant(math.Inf(1)),
+		constant(math.Inf(-1)),
+		minus(constant(0), constant(math.Inf(1))),
+		constant(math.Copysign(0, -1)),
+		constant(1.234567e-7),
+	}
+	rng := rand.New(rand.NewSource(1))
+	for range 500 {
+		trees = append(trees, RandomTree(rng.Intn(30), rng))
+	}
+	for _, tree := range trees {
+		src := Infix(tree)
+		parsed, err := ParseInfixExpression(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if !sameTree(tree, parsed) {
+			t.Errorf("%s read back as %s", tree, parsed)
+		}
+	}
+}
@@ -1,83 +1,166 @@
 package parser
 
 import (
-	"fmt"
-	"regexp"
+	"unicode"
+	"unicode/utf8"
 )
 
-type regexPattern struct {
-	regex   *regexp.Regexp
-	handler regexHandler
+// lexer splits .eqt source into tokens. Whitespace, commas and comments
+// starting with # or // separate tokens and are dropped. Characters that
+// start no token become ILLEGAL tokens, so lexing never fails and the parser
+// reports the error with its position.
+type lexer struct {
+	Tokens       []Token
+	input        string
+	pos          int
+	line, column int
 }
 
-type regexHandler func(l *lexer, regex *regexp.Regexp)
-
-func defaultHandler(tokenType TokenType, value string) regexHandler {
-	return func(l *lexer, regex *regexp.Regexp) {
-		l.advance(len(value))
-		token := NewToken(tokenType, value)
-		l.push(token)
+func Lex(input string) []Token {
+	l := newLexer(input)
+	for !l.at_eof() {
+		l.lexToken()
 	}
+	l.push(NewToken(EOF, "EOF"), l.line, l.column)
+	return l.Tokens
 }
 
-func skipHandler(l *lexer, regex *regexp.Regexp) {
-	match := regex.FindStringIndex(l.remainder())
-	l.advance(match[1])
+func (l *lexer) lexToken() {
+	r := l.peek(0)
+	line, column := l.line, l.column
+	switch {
+	case r == ',' || r == '\uFEFF' || unicode.IsSpace(r):
+		l.advance()
+	case r == '#' || (r == '/' && l.peek(1) == '/'):
+		for !l.at_eof() && l.peek(0) != '\n' {
+			l.advance()
+		}
+	case r == '(':
+		l.advance()
+		l.push(NewToken(OPEN_PAREN, "("), line, column)
+	case r == ')':
+		l.advance()
+		l.push(NewToken(CLOSE_PAREN, ")"), line, column)
+	case r == '=':
+		l.advance()
+		l.push(NewToken(EQUALS, "="), line, column)
+	case l.numberLength() > 0:
+		start, end := l.pos, l.pos+l.numberLength()
+		for l.pos < end {
+			l.advance()
+		}
+		l.push(NewToken(CONSTANT, l.input[start:end]), line, column)
+	case isNameStart(r):
+		start := l.pos
+		for !l.at_eof() && isNamePart(l.peek(0)) {
+			l.advance()
+		}
+		value := l.input[start:l.pos]
+		if isFloatName(value) {
+			l.push(NewToken(CONSTANT, value), line, column)
+		} else {
+			l.push(NewToken(OPERATION, value), line, column)
+		}
+	default:
+		l.advance()
+		l.push(NewToken(ILLEGAL, string(r)), line, column)
+	}
 }
 
-func operationHandler(l *lexer, regex *regexp.Regexp) {
-	match := regex.FindString(l.remainder())
-	token := NewToken(OPERATION, match)
-	l.push(token)
-	l.advance(len(match))
-}
+// numberLength returns the length in bytes of the number at the current
+// position, or 0. Numbers have an optional sign and are written in decimal
+// with an optional fraction and exponent, or as NaN, Inf or Infinity.
+func (l *lexer) numberLength() int {
+	s := l.remainder()
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	if i < len(s) && isNameStart(rune(s[i])) {
+		j := i
+		for j < len(s) && isNamePart(rune(s[j])) {
+			j++
+		}
+		if isFloatName(s[i:j]) {
+			return j
+		}
+		return 0
+	}
 
-func numberHandler(l *lexer, regex *regexp.Regexp) {
-	match := regex.FindString(l.remainder())
-	token := NewToken(CONSTANT, match)
-	l.push(token)
-	l.advance(len(match))
+	digits := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+		digits++
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		for i < len(s) && isDigit(s[i]) {
+			i++
+			digits++
+		}
+	}
+	if digits == 0 {
+		return 0
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if j < len(s) && isDigit(s[j]) {
+			for j < len(s) && isDigit(s[j]) {
+				j++
+			}
+			i = j
+		}
+	}
+	return i
 }
 
-type lexer struct {
-	Tokens   []Token
-	input    string
-	pos      int
-	patterns []regexPattern
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
 }
 
-func Lex(input string) []Token {
-	l := newLexer(input)
+func isNameStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
 
-	for !l.at_eof() {
-		matched := false
+func isNamePart(r rune) bool {
+	return isNameStart(r) || unicode.IsDigit(r)
+}
 
-		for _, pattern := range l.patterns {
-			loc := pattern.regex.FindStringIndex(l.remainder())
-			if loc != nil && loc[0] == 0 {
-				pattern.handler(l, pattern.regex)
-				matched = true
-				break
-			}
-		}
-		if !matched {
-			panic(fmt.Sprintf("Unrecognise token %v", l.remainder()))
-		}
+// advance moves past the next rune.
+func (l *lexer) advance() {
+	r, size := utf8.DecodeRuneInString(l.remainder())
+	l.pos += size
+	if r == '\n' {
+		l.line++
+		l.column = 1
+	} else {
+		l.column++
 	}
-
-	l.push(NewToken(EOF, "EOF"))
-	return l.Tokens
 }
 
-func (l *lexer) advance(n int) {
-	l.pos += n
+// peek returns the rune n runes ahead, or 0 past the end of the input.
+func (l *lexer) peek(n int) rune {
+	s := l.remainder()
+	for ; n > 0 && s != ""; n-- {
+		_, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+	}
+	if s == "" {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
 }
 
 func (l *lexer) remainder() string {
 	return l.input[l.pos:]
 }
 
-func (l *lexer) push(token Token) {
+func (l *lexer) push(token Token, line, column int) {
+	token.line, token.column = line, column
 	l.Tokens = append(l.Tokens, token)
 }
 
@@ -90,13 +173,7 @@ func newLexer(input string) *lexer {
 		Tokens: make([]Token, 0),
 		input:  input,
 		pos:    0,
-		patterns: []regexPattern{
-			{regexp.MustCompile(`\r?\n`), skipHandler},
-			{regexp.MustCompile(`\s?[, ]\s`), skipHandler},
-			{regexp.MustCompile(`\(`), defaultHandler(OPEN_PAREN, "(")},
-			{regexp.MustCompile(`\)`), defaultHandler(CLOSE_PAREN, ")")},
-			{regexp.MustCompile(`[a-zA-Z]+[0-9]*`), operationHandler},
-			{regexp.MustCompile(`[-]?[0-9]+(\.[0-9]+)?`), numberHandler},
-		},
+		line:   1,
+		column: 1,
 	}
 }
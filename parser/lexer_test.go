@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLexNumbers(t *testing.T) {
+	for _, number := range []string{"1", "-2", "1e-5", "+0.5", ".5", "-.5e+2", "1.", "NaN", "-Inf", "+Infinity", "1e999"} {
+		tokens := Lex("Plus(X, " + number + ")")
+		if len(tokens) != 6 || tokens[3].typ != CONSTANT || tokens[3].value != number {
+			t.Errorf("%s lexed as %v", number, tokens)
+		}
+	}
+}
+
+func TestLexSeparatorsAndComments(t *testing.T) {
+	src := "\uFEFF# comment\n(EquationImage\t// another\n Plus(X,Y) Y\n\tX)"
+	var values []string
+	for _, token := range Lex(src) {
+		values = append(values, token.value)
+	}
+	if got, want := strings.Join(values, " "), "( EquationImage Plus ( X Y ) Y X ) EOF"; got != want {
+		t.Errorf("lexed %q, want %q", got, want)
+	}
+}
+
+func TestLexPositions(t *testing.T) {
+	tokens := Lex("(EquationImage\n  Sin(é) ?")
+	want := []string{
+		"line 1, column 1", "line 1, column 2", "line 2, column 3", "line 2, column 6",
+		"line 2, column 7", "line 2, column 8", "line 2, column 10", "line 2, column 11",
+	}
+	if len(tokens) != len(want) {
+		t.Fatalf("lexed %d tokens, want %d", len(tokens), len(want))
+	}
+	for i, token := range tokens {
+		if token.Position() != want[i] {
+			t.Errorf("token %q at %s, want %s", token.value, token.Position(), want[i])
+		}
+	}
+	if tokens[6].typ != ILLEGAL {
+		t.Errorf("? lexed as %v, want ILLEGAL", tokens[6].typ)
+	}
+}
+
+func FuzzLex(f *testing.F) {
+	for _, seed := range []string{
+		"(EquationImage\nPlus(X, Y)\nSin(-0.5e-3)\nCos(Inf))",
+		"let s0 = Sin(X) in Plus(s0, s0)",
+		"# comment\n// comment\n\uFEFF\t,,",
+		"-+.e1-NaN\xff\xfe",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, src string) {
+		tokens := Lex(src)
+		if len(tokens) == 0 || tokens[len(tokens)-1].typ != EOF {
+			t.Fatalf("tokens of %q do not end with EOF", src)
+		}
+		for i, token := range tokens {
+			if token.line < 1 || token.column < 1 {
+				t.Fatalf("token %q of %q at %s", token.value, src, token.Position())
+			}
+			if token.typ == EOF && i != len(tokens)-1 {
+				t.Fatalf("EOF in the middle of the tokens of %q", src)
+			}
+		}
+	})
+}
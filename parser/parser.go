@@ -1,49 +1,164 @@
 package parser
 
 import (
+	"errors"
 	"strconv"
 
 	. "github.com/toantht/texturegen/equation"
 )
 
+// maxExpandedNodes bounds the nodes all uses of let bindings may copy, since
+// nested lets can double the size of a tree with every line.
+const maxExpandedNodes = 1 << 20
+
+// Parse builds the tree of the tokens of Lex. It panics with a *SyntaxError
+// if they do not make a valid tree.
 func Parse(tokens []Token) BaseNode {
 	index := 0
+	// bindings holds the trees named by the enclosing lets. Every use of a
+	// name gets its own copy of the tree.
+	bindings := map[string]BaseNode{}
+	expanded := 0
 
 	var buildTree func(parent BaseNode) BaseNode
+	var buildImage func() BaseNode
+	var buildLet func(parent BaseNode) BaseNode
+	var expect func(parent BaseNode, what string) BaseNode
+	// buildTree returns nil at the end of the input, which it never reads
+	// past.
 	buildTree = func(parent BaseNode) BaseNode {
 		token := tokens[index]
+		if token.typ == EOF {
+			return nil
+		}
 		index++
 
 		switch token.typ {
-		case EOF:
-			return nil
 		case OPEN_PAREN:
 			return buildTree(parent)
 		case CLOSE_PAREN:
 			return buildTree(parent)
 		case CONSTANT:
+			// Numbers too large for a float32 become infinite.
 			value, err := strconv.ParseFloat(token.value, 32)
-			if err != nil {
-				panic(err)
+			if err != nil && !errors.Is(err, strconv.ErrRange) {
+				panic(token.errorf("invalid number %q", token.value))
 			}
 			node := NewOpConstant(float32(value))
 			node.SetParent(parent)
 			return node
 		case OPERATION:
+			if token.value == "EquationImage" {
+				return buildImage()
+			}
+			if token.value == "let" {
+				return buildLet(parent)
+			}
+			if binding, ok := bindings[token.value]; ok {
+				expanded += binding.NodeCount()
+				if expanded > maxExpandedNodes {
+					panic(token.errorf("lets expand to more than %d nodes", maxExpandedNodes))
+				}
+				node := CopyTree(binding)
+				node.SetParent(parent)
+				return node
+			}
 			node := tokenToNode(token)
 			node.SetParent(parent)
 			for i := range node.GetChildren() {
-				node.GetChildren()[i] = buildTree(node)
+				node.GetChildren()[i] = expect(node, "an operand of "+token.value)
 			}
 			return node
 		}
-		return nil
+		panic(token.errorf("unexpected %q", token.value))
+	}
+
+	// expect builds a tree that has to be there, what names it in the error.
+	expect = func(parent BaseNode, what string) BaseNode {
+		node := buildTree(parent)
+		if node == nil {
+			panic(tokens[index].errorf("expected %s", what))
+		}
+		return node
+	}
+
+	// Settings come before the channels of an image, as Name(arg, ...).
+	buildImage = func() BaseNode {
+		image := NewOpImage()
+		for tokens[index].typ == OPERATION && !isOperation(tokens[index].value) && tokens[index].value != "let" {
+			setting := Setting{Name: tokens[index].value, Args: []string{}}
+			index++
+			if tokens[index].typ == OPEN_PAREN {
+				index++
+			}
+			for tokens[index].typ != CLOSE_PAREN && tokens[index].typ != EOF {
+				setting.Args = append(setting.Args, tokens[index].value)
+				index++
+			}
+			if tokens[index].typ == CLOSE_PAREN {
+				index++
+			}
+			image.Settings = append(image.Settings, setting)
+		}
+
+		for i := range image.GetChildren() {
+			image.GetChildren()[i] = expect(image, "a channel")
+		}
+
+		// An optional fourth channel is the alpha channel.
+		for tokens[index].typ == CLOSE_PAREN {
+			index++
+		}
+		if tokens[index].typ == OPERATION || tokens[index].typ == CONSTANT {
+			image.SetChildren(append(image.GetChildren(), buildTree(image)))
+		}
+		return image
+	}
+
+	// let name = value in body
+	buildLet = func(parent BaseNode) BaseNode {
+		name := tokens[index]
+		if name.typ != OPERATION || isOperation(name.value) || name.value == "let" {
+			panic(name.errorf("invalid let name %q", name.value))
+		}
+		index++
+		if tokens[index].typ != EQUALS {
+			panic(tokens[index].errorf("expected = after let %s", name))
+		}
+		index++
+		value := expect(nil, "the value of let "+name.value)
+		for tokens[index].typ == CLOSE_PAREN {
+			index++
+		}
+		if tokens[index].typ != OPERATION || tokens[index].value != "in" {
+			panic(tokens[index].errorf("expected in after let %s", name))
+		}
+		index++
+
+		outer, shadowed := bindings[name.value]
+		bindings[name.value] = value
+		body := expect(parent, "the body of let "+name.value)
+		if shadowed {
+			bindings[name.value] = outer
+		} else {
+			delete(bindings, name.value)
+		}
+		return body
 	}
 
 	return buildTree(nil)
 
 }
 
+func isOperation(name string) bool {
+	for _, op := range Ops {
+		if op.Name == name {
+			return true
+		}
+	}
+	return name == "EquationImage"
+}
+
 func tokenToNode(token Token) BaseNode {
 	switch token.value {
 	case "X":
@@ -69,5 +184,5 @@ func tokenToNode(token Token) BaseNode {
 	case "EquationImage":
 		return NewOpImage()
 	}
-	panic("unknown token")
+	panic(token.errorf("unknown operation %q", token.value))
 }
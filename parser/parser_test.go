@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	. "github.com/toantht/texturegen/equation"
+	. "github.com/toantht/texturegen/equation/equationtest"
+)
+
+// parse returns the message of the SyntaxError the parser panicked with, or
+// "".
+func parse(src string) (message string) {
+	defer func() {
+		if r := recover(); r != nil {
+			message = r.(*SyntaxError).Error()
+		}
+	}()
+	Parse(Lex(src))
+	return ""
+}
+
+func TestParseErrorsHavePositions(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{"(EquationImage X Y)", "line 1, column 20: expected a channel"},
+		{"(EquationImage X Y Plus(X", "line 1, column 26: expected an operand of Plus"},
+		{"(EquationImage\nX Y ?)", "line 2, column 5: unexpected \"?\""},
+		{"(EquationImage X Y Foo)", "line 1, column 20: unknown operation \"Foo\""},
+		{"(EquationImage let s0 = ", "line 1, column 25: expected the value of let s0"},
+		{"(EquationImage let s0 = X Y", "line 1, column 27: expected in after let s0"},
+		{"(EquationImage X = Y", "line 1, column 18: unexpected \"=\""},
+	}
+	for _, test := range tests {
+		if got := parse(test.src); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.src, got, test.want)
+		}
+	}
+}
+
+func TestParseBoundsLetExpansion(t *testing.T) {
+	src := "(EquationImage let a = X in " + strings.Repeat("let a = Plus(a, a) in ", 40) + "a X Y)"
+	if got := parse(src); !strings.Contains(got, "lets expand to more than") {
+		t.Errorf("doubling lets parsed with %q", got)
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"(EquationImage\nViewport(0, 0, 2, 0)\nPlus(X, Y)\nSin(X)\nY)",
+		"(EquationImage let s0 = Sin(X) in Plus(s0, s0) X Y X)",
+		"(EquationImage X Y)",
+		"let in = (",
+	} {
+		f.Add(seed)
+	}
+	// Malformed input makes the parser panic with a SyntaxError, never
+	// with anything else.
+	f.Fuzz(func(t *testing.T, src string) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(*SyntaxError); !ok {
+					t.Fatalf("%q: %v", src, r)
+				}
+			}
+		}()
+		Parse(Lex(src))
+	})
+}
+
+func TestSharedRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	for range 300 {
+		shared := RandomTree(rng.Intn(6), rng)
+		trees := []BaseNode{
+			WithChildren(NewOpPlus(), shared, WithChildren(NewOpSin(), CopyTree(shared))),
+			RandomTree(rng.Intn(15), rng),
+			WithChildren(NewOpMult(), RandomTree(rng.Intn(6), rng), CopyTree(shared)),
+		}
+		src := fmt.Sprintf("(EquationImage %s %s %s)", Shared(trees[0]), Shared(trees[1]), Shared(trees[2]))
+		image := Parse(Lex(src))
+		for i, tree := range trees {
+			if got := image.GetChildren()[i]; !sameTree(tree, got) {
+				t.Fatalf("%s read back as %s", Shared(tree), got)
+			}
+		}
+	}
+}
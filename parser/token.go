@@ -1,5 +1,7 @@
 package parser
 
+import "fmt"
+
 type TokenType int
 
 const (
@@ -8,17 +10,31 @@ const (
 	CLOSE_PAREN
 	OPERATION
 	CONSTANT
+	EQUALS
+	ILLEGAL
 )
 
+// Token is a piece of the source. Its line and column count from 1.
 type Token struct {
-	typ   TokenType
-	value string
+	typ          TokenType
+	value        string
+	line, column int
 }
 
 func NewToken(typ TokenType, value string) Token {
-	return Token{typ, value}
+	return Token{typ: typ, value: value}
 }
 
 func (t Token) String() string {
 	return t.value
 }
+
+// Position returns where the token starts as "line 3, column 7".
+func (t Token) Position() string {
+	return fmt.Sprintf("line %d, column %d", t.line, t.column)
+}
+
+// errorf returns a SyntaxError at the position of the token.
+func (t Token) errorf(format string, args ...any) *SyntaxError {
+	return &SyntaxError{t.line, t.column, fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// qualityProbeSize is the width and height of the image a child is rendered
+// at to judge it.
+const qualityProbeSize = 16
+
+// qualityGate rejects children that would waste a slot of the grid: flat
+// images, images of almost a single color and images mostly made of NaN.
+// Rejected children are bred again, at most retries times. A zero threshold
+// disables its check, zero retries the whole gate.
+type qualityGate struct {
+	// minVariance is the minimum of the summed variance of the color
+	// components, in [0,1], over the pixels that are not NaN.
+	minVariance float64
+	// maxNaN is the maximum fraction of NaN pixels.
+	maxNaN float64
+	// minColors is the minimum number of distinct 8-bit colors.
+	minColors int
+	retries   int
+}
+
+var defaultQualityGate = qualityGate{minVariance: 0.002, maxNaN: 0.5, minColors: 8, retries: 10}
+
+type rejection int
+
+const (
+	accepted rejection = iota
+	rejectedFlat
+	rejectedVariance
+	rejectedNaN
+	rejectedColors
+	rejectionCount
+)
+
+func (r rejection) String() string {
+	switch r {
+	case accepted:
+		return "accepted"
+	case rejectedFlat:
+		return "flat"
+	case rejectedVariance:
+		return "low variance"
+	case rejectedNaN:
+		return "NaN"
+	case rejectedColors:
+		return "few colors"
+	}
+	return "unknown"
+}
+
+// quality holds the measures of a probe render.
+type quality struct {
+	variance float64
+	nan      float64
+	colors   int
+}
+
+// measureQuality renders t at qualityProbeSize without supersampling.
+func measureQuality(t *textureEquation) quality {
+	r := newRenderer(t, t.viewport, renderOptions{pointSampling, defaultTonemap}, qualityProbeSize, qualityProbeSize)
+	var sum, sumSquares [4]float64
+	colors := make(map[[4]uint8]bool)
+	nan, n := 0, 0
+	for y := 0; y < qualityProbeSize; y++ {
+		for x := 0; x < qualityProbeSize; x++ {
+			if t.hasNaN(r.eval(r.viewport.screenToDomain(float32(x), float32(y), r.width, r.height))) {
+				nan++
+				continue
+			}
+			c := r.colorAt(float32(x), float32(y))
+			colors[[4]uint8{toByte(c[0]), toByte(c[1]), toByte(c[2]), toByte(c[3])}] = true
+			for i, v := range c {
+				sum[i] += float64(v)
+				sumSquares[i] += float64(v) * float64(v)
+			}
+			n++
+		}
+	}
+
+	result := quality{nan: float64(nan) / (qualityProbeSize * qualityProbeSize), colors: len(colors)}
+	if n > 0 {
+		for i := range sum {
+			mean := sum[i] / float64(n)
+			result.variance += math.Max(sumSquares[i]/float64(n)-mean*mean, 0)
+		}
+	}
+	return result
+}
+
+// hasNaN reports whether one of the values of the channels of t is NaN.
+func (t *textureEquation) hasNaN(values [4]float32) bool {
+	for i, v := range values {
+		if i == 3 && t.a == nil {
+			break
+		}
+		if math.IsNaN(float64(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns why t fails the gate, or accepted. Flat equations are
+// spotted by range analysis before anything is rendered.
+func (g qualityGate) check(t *textureEquation) rejection {
+	if t.flat() {
+		return rejectedFlat
+	}
+	if g.minVariance <= 0 && g.maxNaN <= 0 && g.minColors <= 0 {
+		return accepted
+	}
+	q := measureQuality(t)
+	switch {
+	case g.maxNaN > 0 && q.nan > g.maxNaN:
+		return rejectedNaN
+	case q.variance < g.minVariance:
+		return rejectedVariance
+	case q.colors < g.minColors:
+		return rejectedColors
+	}
+	return accepted
+}
+
+// rejectionStats counts the children judged by a qualityGate and the
+// reasons they were rejected for.
+type rejectionStats struct {
+	checked int
+	counts  [rejectionCount]int
+}
+
+func (s *rejectionStats) add(other rejectionStats) {
+	s.checked += other.checked
+	for i, n := range other.counts {
+		s.counts[i] += n
+	}
+}
+
+func (s rejectionStats) rejected() int {
+	return s.checked - s.counts[accepted]
+}
+
+// String summarises the stats as "rejected 5 of 14: 3 flat, 2 NaN".
+func (s rejectionStats) String() string {
+	reasons := make([]string, 0)
+	for r := rejectedFlat; r < rejectionCount; r++ {
+		if s.counts[r] > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d %s", s.counts[r], r))
+		}
+	}
+	line := fmt.Sprintf("rejected %d of %d", s.rejected(), s.checked)
+	if len(reasons) > 0 {
+		line += ": " + strings.Join(reasons, ", ")
+	}
+	return line
+}
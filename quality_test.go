@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+const (
+	flatSource = "(EquationImage\n0.5\n0.2\n0.1)"
+	// Range analysis treats both X independently, so only the render finds
+	// this one flat.
+	cancellingSource = "(EquationImage\nMinus(X, X)\n0.2\n0.1)"
+	faintSource      = "(EquationImage\nMult(X, 0.001)\n0.2\n0.1)"
+	gradientSource   = "(EquationImage\nX\nY\nX)"
+	noisySource      = "(EquationImage\nSin(Mult(X, 1000))\nCos(Mult(Y, 1337))\nSin(Mult(Mult(X, Y), 4000)))"
+	// Infinities of opposite signs add up to NaN left of the Y axis.
+	halfNaNSource = "(EquationImage\nPlus(Div(1, 0), Div(X, 0))\nY\nX)"
+)
+
+func TestQualityGate(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		gate qualityGate
+		want rejection
+	}{
+		{"flat", flatSource, defaultQualityGate, rejectedFlat},
+		{"flat with the gate off", flatSource, qualityGate{}, rejectedFlat},
+		{"cancelling", cancellingSource, defaultQualityGate, rejectedVariance},
+		{"cancelling with the gate off", cancellingSource, qualityGate{}, accepted},
+		{"faint", faintSource, defaultQualityGate, rejectedVariance},
+		{"gradient", gradientSource, defaultQualityGate, accepted},
+		{"noisy", noisySource, defaultQualityGate, accepted},
+		{"noisy with many colors required", noisySource, qualityGate{minColors: 250}, rejectedColors},
+		{"half NaN", halfNaNSource, defaultQualityGate, rejectedNaN},
+		{"half NaN allowed", halfNaNSource, qualityGate{maxNaN: 0.6, minColors: 1}, accepted},
+		{"NaN check off", halfNaNSource, qualityGate{minColors: 1}, accepted},
+	}
+	for _, test := range tests {
+		if got := test.gate.check(testTexture(t, test.src)); got != test.want {
+			t.Errorf("%s: %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestQualityGateThresholds(t *testing.T) {
+	noisy, halfNaN := testTexture(t, noisySource), testTexture(t, halfNaNSource)
+	q, qNaN := measureQuality(noisy), measureQuality(halfNaN)
+	if q.nan != 0 || q.variance < 0.1 || q.colors < 200 {
+		t.Fatalf("noisy image measures %+v", q)
+	}
+	if qNaN.nan < 0.4 || qNaN.nan > 0.6 {
+		t.Fatalf("half NaN image measures %+v", qNaN)
+	}
+
+	// Every threshold is inclusive.
+	tests := []struct {
+		name string
+		tex  *textureEquation
+		gate qualityGate
+		want rejection
+	}{
+		{"variance at the minimum", noisy, qualityGate{minVariance: q.variance}, accepted},
+		{"variance below the minimum", noisy, qualityGate{minVariance: math.Nextafter(q.variance, 1)}, rejectedVariance},
+		{"colors at the minimum", noisy, qualityGate{minColors: q.colors}, accepted},
+		{"colors below the minimum", noisy, qualityGate{minColors: q.colors + 1}, rejectedColors},
+		{"NaN at the maximum", halfNaN, qualityGate{maxNaN: qNaN.nan}, accepted},
+		{"NaN above the maximum", halfNaN, qualityGate{maxNaN: math.Nextafter(qNaN.nan, 0)}, rejectedNaN},
+	}
+	for _, test := range tests {
+		if got := test.gate.check(test.tex); got != test.want {
+			t.Errorf("%s: %s, want %s", test.name, got, test.want)
+		}
+	}
+}
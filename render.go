@@ -0,0 +1,201 @@
+package main
+
+import (
+	"image"
+	"math"
+
+	eqt "github.com/toantht/texturegen/equation"
+)
+
+// renderOptions are the choices made for every render rather than stored
+// with the equation.
+type renderOptions struct {
+	sampling sampling
+	tonemap  tonemap
+}
+
+var (
+	// previewOptions are used for thumbnails and the zoom view.
+	previewOptions = renderOptions{previewSampling, defaultTonemap}
+	// exportOptions are used for the files written by headless commands.
+	exportOptions = renderOptions{exportSampling, defaultTonemap}
+)
+
+// renderer evaluates an equation for the pixels of a width by height image.
+type renderer struct {
+	equation      *textureEquation
+	viewport      viewport
+	options       renderOptions
+	width, height int
+	ranges        [4]channelRange
+
+	// program evaluates the channels, values holds the value of each of
+	// its nodes at the last point.
+	program *eqt.DAG
+	values  []float32
+}
+
+func newRenderer(t *textureEquation, vp viewport, opts renderOptions, width, height int) *renderer {
+	r := &renderer{equation: t, viewport: vp, options: opts, width: width, height: height, program: t.program()}
+	r.values = make([]float32, r.program.Len())
+	if opts.tonemap.mode == tonemapNormalize {
+		r.measure()
+	}
+	return r
+}
+
+// eval returns the values of the channel trees at x, y, alpha last. Alpha is
+// 0 without an alpha channel.
+func (r *renderer) eval(x, y float32) [4]float32 {
+	r.program.Eval(x, y, r.values)
+	var values [4]float32
+	for i, root := range r.program.Roots {
+		values[i] = r.values[root]
+	}
+	return values
+}
+
+// measure finds the range of every channel over a low resolution pre-pass,
+// ignoring values that are not finite.
+func (r *renderer) measure() {
+	for i := range r.ranges {
+		r.ranges[i] = channelRange{float32(math.Inf(1)), float32(math.Inf(-1))}
+	}
+	for y := 0; y < probeSize; y++ {
+		for x := 0; x < probeSize; x++ {
+			fx, fy := r.viewport.screenToDomain(float32(x)*float32(r.width)/probeSize, float32(y)*float32(r.height)/probeSize, r.width, r.height)
+			for i, v := range r.eval(fx, fy) {
+				if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+					continue
+				}
+				r.ranges[i].low = min(r.ranges[i].low, v)
+				r.ranges[i].high = max(r.ranges[i].high, v)
+			}
+		}
+	}
+}
+
+// colorAt returns the color at the point x, y, in pixels.
+func (r *renderer) colorAt(x, y float32) floatColor {
+	fx, fy := r.viewport.screenToDomain(x, y, r.width, r.height)
+	t := r.equation
+	values := r.eval(fx, fy)
+
+	c := [4]float32{0, 0, 0, 1}
+	for i := range values {
+		if i == 3 && t.a == nil {
+			break
+		}
+		v, ok := r.options.tonemap.mode.apply(values[i], r.ranges[i])
+		if !ok {
+			return colorFromRGBA(r.options.tonemap.nan)
+		}
+		c[i] = v
+	}
+
+	var result floatColor
+	if t.colorMode == colorPalette {
+		result = t.palette.at(c[0])
+	} else {
+		result = t.colorMode.toRGB(c[0], c[1], c[2])
+	}
+	if t.a != nil {
+		alpha := clamp01(c[3])
+		result = floatColor{result[0] * alpha, result[1] * alpha, result[2] * alpha, alpha}
+	}
+	return result
+}
+
+// channelAt returns the value of one channel tree at the point x, y, in
+// pixels, mapped to [0,1] by the tonemap. NaN gives 0.
+func (r *renderer) channelAt(x, y float32, channel int) float32 {
+	fx, fy := r.viewport.screenToDomain(x, y, r.width, r.height)
+	v, ok := r.options.tonemap.mode.apply(r.eval(fx, fy)[channel], r.ranges[channel])
+	if !ok {
+		return 0
+	}
+	return v
+}
+
+// render returns the image, or nil if cancelled returns true before it is
+// complete.
+func (r *renderer) render(cancelled func() bool) *floatImage {
+	s := r.options.sampling
+	texture := newFloatImage(r.width, r.height)
+	if s.samples <= 1 || s.adaptive > 0 {
+		for y := 0; y < r.height; y++ {
+			if cancelled() {
+				return nil
+			}
+			for x := 0; x < r.width; x++ {
+				texture.set(x, y, r.colorAt(float32(x), float32(y)))
+			}
+		}
+		if s.samples <= 1 {
+			return texture
+		}
+	}
+
+	base := texture
+	if s.adaptive > 0 {
+		texture = newFloatImage(r.width, r.height)
+		copy(texture.pix, base.pix)
+	}
+	for y := 0; y < r.height; y++ {
+		if cancelled() {
+			return nil
+		}
+		for x := 0; x < r.width; x++ {
+			if s.adaptive > 0 && deviation(base, x, y) <= s.adaptive {
+				continue
+			}
+			texture.set(x, y, r.supersample(x, y))
+		}
+	}
+	return texture
+}
+
+// renderFloat evaluates t over vp without touching the GPU, so it can be
+// used before the game loop starts and in headless mode.
+func renderFloat(t *textureEquation, vp viewport, opts renderOptions, width, height int) *floatImage {
+	return newRenderer(t, vp, opts, width, height).render(func() bool { return false })
+}
+
+// renderTexture is renderFloat quantised to 8 bits per component.
+func renderTexture(t *textureEquation, vp viewport, opts renderOptions, width, height int) *image.RGBA {
+	return renderFloat(t, vp, opts, width, height).rgba()
+}
+
+// renderPasses are the block sizes of the passes of renderProgressive, from
+// coarse to full resolution.
+var renderPasses = []int{8, 4, 2, 1}
+
+// renderProgressive renders t several times, each time at a higher
+// resolution, and hands every pass to done. Only the last pass is
+// supersampled. It stops as soon as cancelled returns true.
+func renderProgressive(t *textureEquation, vp viewport, opts renderOptions, width, height int, cancelled func() bool, done func(*image.RGBA)) {
+	r := newRenderer(t, vp, opts, width, height)
+	for _, block := range renderPasses {
+		if block == 1 {
+			if texture := r.render(cancelled); texture != nil {
+				done(texture.rgba())
+			}
+			return
+		}
+		texture := newFloatImage(width, height)
+		for y := 0; y < height; y += block {
+			if cancelled() {
+				return
+			}
+			for x := 0; x < width; x += block {
+				c := r.colorAt(float32(x), float32(y))
+				for by := y; by < min(y+block, height); by++ {
+					for bx := x; bx < min(x+block, width); bx++ {
+						texture.set(bx, by, c)
+					}
+				}
+			}
+		}
+		done(texture.rgba())
+	}
+}
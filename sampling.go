@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type samplePattern int
+
+const (
+	patternGrid samplePattern = iota
+	patternJittered
+)
+
+var samplePatterns = []samplePattern{patternGrid, patternJittered}
+
+func (p samplePattern) String() string {
+	switch p {
+	case patternGrid:
+		return "grid"
+	case patternJittered:
+		return "jittered"
+	}
+	return "unknown"
+}
+
+func parseSamplePattern(name string) (samplePattern, error) {
+	for _, p := range samplePatterns {
+		if p.String() == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown sample pattern %q", name)
+}
+
+// filter weighs the samples of a pixel by their offset from it in pixels.
+type filter int
+
+const (
+	filterBox filter = iota
+	filterTent
+	filterGaussian
+)
+
+var filters = []filter{filterBox, filterTent, filterGaussian}
+
+func (f filter) String() string {
+	switch f {
+	case filterBox:
+		return "box"
+	case filterTent:
+		return "tent"
+	case filterGaussian:
+		return "gaussian"
+	}
+	return "unknown"
+}
+
+func parseFilter(name string) (filter, error) {
+	for _, f := range filters {
+		if f.String() == name {
+			return f, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown filter %q", name)
+}
+
+// radius is the distance in pixels the samples of a pixel are spread over.
+func (f filter) radius() float32 {
+	switch f {
+	case filterTent:
+		return 1
+	case filterGaussian:
+		return 1.5
+	}
+	return 0.5
+}
+
+func (f filter) weight(dx, dy float32) float32 {
+	switch f {
+	case filterTent:
+		return max(1-abs32(dx), 0) * max(1-abs32(dy), 0)
+	case filterGaussian:
+		const sigma = 0.5
+		return float32(math.Exp(-float64(dx*dx+dy*dy) / (2 * sigma * sigma)))
+	}
+	return 1
+}
+
+func abs32(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
+
+// sampling says how many points are evaluated for every pixel and how they
+// are combined. samples is the number of samples per side, so a pixel takes
+// samples² of them. With adaptive above zero, only the pixels whose 3×3
+// neighbourhood has a standard deviation above adaptive, in [0,1], are
+// supersampled; the others keep a single sample.
+type sampling struct {
+	samples  int
+	pattern  samplePattern
+	filter   filter
+	adaptive float64
+}
+
+var (
+	// pointSampling evaluates one point per pixel.
+	pointSampling   = sampling{samples: 1}
+	previewSampling = sampling{samples: 2, pattern: patternJittered, filter: filterBox, adaptive: 0.05}
+	exportSampling  = sampling{samples: 4, pattern: patternJittered, filter: filterTent}
+)
+
+// supersample combines the samples of the pixel x, y with the filter of the
+// sampling options.
+func (r *renderer) supersample(x, y int) floatColor {
+	s := r.options.sampling
+	radius := s.filter.radius()
+	var sum floatColor
+	var total float32
+	for i := 0; i < s.samples; i++ {
+		for j := 0; j < s.samples; j++ {
+			jx, jy := float32(0.5), float32(0.5)
+			if s.pattern == patternJittered {
+				n := (i*s.samples + j) * 2
+				jx, jy = jitter(x, y, n), jitter(x, y, n+1)
+			}
+			dx := (float32(j)+jx)/float32(s.samples)*2*radius - radius
+			dy := (float32(i)+jy)/float32(s.samples)*2*radius - radius
+
+			w := s.filter.weight(dx, dy)
+			c := r.colorAt(float32(x)+dx, float32(y)+dy)
+			for k := range sum {
+				sum[k] += w * c[k]
+			}
+			total += w
+		}
+	}
+	if total == 0 {
+		return r.colorAt(float32(x), float32(y))
+	}
+	for k := range sum {
+		sum[k] /= total
+	}
+	return sum
+}
+
+// jitter returns a number in [0,1) that only depends on its arguments, so
+// jittered renders are reproducible.
+func jitter(x, y, n int) float32 {
+	h := uint32(x)*73856093 ^ uint32(y)*19349663 ^ uint32(n)*83492791
+	h ^= h >> 13
+	h *= 0x5bd1e995
+	h ^= h >> 15
+	return float32(h&0xffffff) / (1 << 24)
+}
+
+// deviation is the standard deviation of the colors around the pixel x, y.
+func deviation(img *floatImage, x, y int) float64 {
+	var sum, sumSquares [3]float64
+	n := 0.0
+	for ny := max(y-1, 0); ny <= min(y+1, img.height-1); ny++ {
+		for nx := max(x-1, 0); nx <= min(x+1, img.width-1); nx++ {
+			c := img.at(nx, ny)
+			for i, v := range c[:3] {
+				f := float64(v)
+				sum[i] += f
+				sumSquares[i] += f * f
+			}
+			n++
+		}
+	}
+	variance := 0.0
+	for i := range sum {
+		mean := sum[i] / n
+		variance += sumSquares[i]/n - mean*mean
+	}
+	return math.Sqrt(max(variance/3, 0))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/toantht/texturegen/parser"
+)
+
+func testTexture(t *testing.T, src string) *textureEquation {
+	t.Helper()
+	tex, err := textureEquationFromImage(parser.Parse(parser.Lex(src)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tex
+}
+
+func TestAdaptiveSamplingMatchesUniform(t *testing.T) {
+	const size = 32
+	uniform := sampling{samples: 4, pattern: patternGrid, filter: filterBox}
+	adaptive := uniform
+	adaptive.adaptive = 0.05
+	tests := []struct {
+		name string
+		src  string
+		// smooth functions stay within a few steps of the uniform render
+		// everywhere.
+		smooth bool
+	}{
+		{"smooth", "(EquationImage\nMult(X, 0.4)\nMult(Sin(Y), 0.4)\nMult(Cos(Mult(X, Y)), 0.4))", true},
+		{"busy", "(EquationImage\nSin(Mult(X, 40))\nY\nCos(Mult(Y, 60)))", false},
+	}
+	for _, test := range tests {
+		tex := testTexture(t, test.src)
+		point := renderTexture(tex, tex.viewport, renderOptions{pointSampling, defaultTonemap}, size, size).Pix
+		want := renderTexture(tex, tex.viewport, renderOptions{uniform, defaultTonemap}, size, size).Pix
+		got := renderTexture(tex, tex.viewport, renderOptions{adaptive, defaultTonemap}, size, size).Pix
+		refined := 0
+		for i := 0; i < len(got); i += 4 {
+			pixel := got[i : i+4]
+			// A pixel is either refined exactly as in the uniform render or
+			// keeps its single sample.
+			switch {
+			case bytes.Equal(pixel, want[i:i+4]):
+				if !bytes.Equal(pixel, point[i:i+4]) {
+					refined++
+				}
+			case !bytes.Equal(pixel, point[i:i+4]):
+				t.Fatalf("%s: pixel %d is %v, uniform sampling gives %v, one sample %v", test.name, i/4, pixel, want[i:i+4], point[i:i+4])
+			}
+			for k := range pixel {
+				if d := int(pixel[k]) - int(want[i+k]); test.smooth && (d < -3 || d > 3) {
+					t.Errorf("%s: pixel %d is %v, uniform sampling gives %v", test.name, i/4, pixel, want[i:i+4])
+					break
+				}
+			}
+		}
+		if !test.smooth && refined == 0 {
+			t.Errorf("%s: no pixel was refined", test.name)
+		}
+	}
+}
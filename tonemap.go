@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// tonemapMode says how the values of the channel trees, nominally in
+// [-0.5,0.5], are brought into [0,1].
+type tonemapMode int
+
+const (
+	// tonemapWrap wraps values around, like a byte overflowing.
+	tonemapWrap tonemapMode = iota
+	tonemapClamp
+	// tonemapSigmoid compresses every value smoothly with tanh.
+	tonemapSigmoid
+	// tonemapNormalize stretches the range of every channel observed in a
+	// low resolution pre-pass to [0,1].
+	tonemapNormalize
+)
+
+var tonemapModes = []tonemapMode{tonemapWrap, tonemapClamp, tonemapSigmoid, tonemapNormalize}
+
+func (m tonemapMode) String() string {
+	switch m {
+	case tonemapWrap:
+		return "wrap"
+	case tonemapClamp:
+		return "clamp"
+	case tonemapSigmoid:
+		return "sigmoid"
+	case tonemapNormalize:
+		return "normalize"
+	}
+	return "unknown"
+}
+
+// next returns the mode following m, wrapping around after the last one.
+func (m tonemapMode) next() tonemapMode {
+	return tonemapModes[(int(m)+1)%len(tonemapModes)]
+}
+
+func parseTonemapMode(name string) (tonemapMode, error) {
+	for _, m := range tonemapModes {
+		if m.String() == name {
+			return m, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown tonemap %q", name)
+}
+
+// tonemap maps channel values to [0,1]. Pixels where a channel is NaN get
+// the color nan.
+type tonemap struct {
+	mode tonemapMode
+	nan  color.RGBA
+}
+
+var defaultTonemap = tonemap{mode: tonemapWrap, nan: color.RGBA{0, 0, 0, 255}}
+
+// probeSize is the width and height of the pre-pass of tonemapNormalize.
+const probeSize = 32
+
+// channelRange is the range of values of a channel seen by the pre-pass.
+type channelRange struct {
+	low, high float32
+}
+
+// apply maps v to [0,1]. r is only used by tonemapNormalize. It reports
+// false if v is NaN.
+func (m tonemapMode) apply(v float32, r channelRange) (float32, bool) {
+	if v != v {
+		return 0, false
+	}
+	switch m {
+	case tonemapClamp:
+		return min(max(v+127.0/255, 0), 1), true
+	case tonemapSigmoid:
+		return 0.5 + 0.5*float32(math.Tanh(2*float64(v))), true
+	case tonemapNormalize:
+		if r.high <= r.low {
+			return 0.5, true
+		}
+		// In float64 so ranges as wide as float32 allows do not overflow.
+		t := (float64(v) - float64(r.low)) / (float64(r.high) - float64(r.low))
+		return float32(min(max(t, 0), 1)), true
+	}
+
+	// Infinite values have no meaningful remainder, they are clamped.
+	if math.IsInf(float64(v), 0) {
+		return min(max(v, 0), 1), true
+	}
+	// In float64, since v*255 overflows float32 for large finite v.
+	x := math.Mod(math.Trunc(float64(v)*255+127), 256)
+	if x < 0 {
+		x += 256
+	}
+	return float32(x) / 255, true
+}
+
+// parseHexColor reads a color written as rrggbb or #rrggbb.
+func parseHexColor(s string) (color.RGBA, error) {
+	s = strings.TrimPrefix(s, "#")
+	value, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || len(s) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q, expected rrggbb", s)
+	}
+	return color.RGBA{uint8(value >> 16), uint8(value >> 8), uint8(value), 255}, nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTonemapEdgeValues(t *testing.T) {
+	inf := float32(math.Inf(1))
+	nan := float32(math.NaN())
+	negativeZero := float32(math.Copysign(0, -1))
+	abovePositive := math.Nextafter32(0.5, 1)
+	belowNegative := math.Nextafter32(-0.5, -1)
+	r := channelRange{-0.5, 0.5}
+
+	// want is the byte of every mode, in the order of tonemapModes. NaN must
+	// be reported and leave the byte to the NaN color.
+	tests := []struct {
+		name string
+		v    float32
+		nan  bool
+		want [4]uint8
+	}{
+		{"zero", 0, false, [4]uint8{127, 127, 128, 128}},
+		{"negative zero", negativeZero, false, [4]uint8{127, 127, 128, 128}},
+		{"+Inf", inf, false, [4]uint8{255, 255, 255, 255}},
+		{"-Inf", -inf, false, [4]uint8{0, 0, 0, 0}},
+		{"NaN", nan, true, [4]uint8{}},
+		{"+MaxFloat32", math.MaxFloat32, false, [4]uint8{0, 255, 255, 255}},
+		{"-MaxFloat32", -math.MaxFloat32, false, [4]uint8{0, 0, 0, 0}},
+		{"just above 0.5", abovePositive, false, [4]uint8{254, 255, 225, 255}},
+		{"just below -0.5", belowNegative, false, [4]uint8{0, 0, 30, 0}},
+	}
+	for _, test := range tests {
+		for i, mode := range tonemapModes {
+			v, ok := mode.apply(test.v, r)
+			if ok == test.nan {
+				t.Errorf("%s: %s reported ok = %v", mode, test.name, ok)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if math.IsNaN(float64(v)) || v < 0 || v > 1 {
+				t.Errorf("%s: %s mapped to %v, outside [0,1]", mode, test.name, v)
+			}
+			if got := toByte(v); got != test.want[i] {
+				t.Errorf("%s: %s mapped to byte %d, want %d", mode, test.name, got, test.want[i])
+			}
+		}
+	}
+}
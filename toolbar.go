@@ -0,0 +1,76 @@
+package main
+
+import (
+	"image"
+
+	eqt "github.com/toantht/texturegen/equation"
+	"github.com/toantht/texturegen/gui"
+)
+
+// newToolbar builds the widgets below the texture grid.
+func (g *Game) newToolbar() *gui.Screen {
+	modes := make([]string, len(crossoverModes))
+	for i, mode := range crossoverModes {
+		modes[i] = mode.String()
+	}
+	crossover := gui.NewDropdown(modes, int(g.evolveOptions.crossover), func(selected int) {
+		g.evolveOptions.crossover = crossoverModes[selected]
+	})
+	crossover.OpenUp = true
+
+	fresh := gui.NewSlider("fresh", 0, 3, 1, float64(g.evolveOptions.freshSlots), func(value float64) {
+		g.evolveOptions.freshSlots = int(value)
+	})
+	fresh.Width = 70
+
+	diversity := gui.NewToggle("diverse", g.evolveOptions.minDistance > 0, func(value bool) {
+		g.evolveOptions.minDistance = 0
+		if value {
+			g.evolveOptions.minDistance = defaultMinDistance
+		}
+	})
+
+	quality := gui.NewToggle("filter", g.evolveOptions.quality.retries > 0, func(value bool) {
+		g.evolveOptions.quality.retries = 0
+		if value {
+			g.evolveOptions.quality.retries = defaultQualityGate.retries
+		}
+	})
+
+	evolveButton := gui.NewButton("Evolve", g.evolveSelected)
+	g.rejections = gui.NewLabel("")
+
+	panel := gui.NewPanel(gui.Row, crossover, fresh, evolveButton, diversity, quality, g.rejections)
+	panel.Align = gui.AlignCenter
+	panel.Spacing = 8
+	return gui.NewScreen(panel)
+}
+
+func (g *Game) placeToolbar() {
+	width, height := g.layout.screenWidth, g.layout.screenHeight
+	g.toolbar.SetBounds(image.Rect(0, height-toolbarHeight, width, height))
+}
+
+// evolveSelected replaces every texture with a child of the selected ones.
+func (g *Game) evolveSelected() {
+	selectedEquations := make([]*textureEquation, 0)
+	for _, t := range g.textures {
+		if t != nil && t.selected {
+			selectedEquations = append(selectedEquations, t.equation)
+		}
+	}
+	if len(selectedEquations) > 0 {
+		eqs, stats := evolve(selectedEquations, len(g.textures), g.evolveOptions, eqt.DefaultRand)
+		for i, t := range g.textures {
+			if t != nil {
+				t.applyEquation(eqs[i])
+				t.selected = false
+			}
+		}
+		g.rejections.Text = ""
+		if stats.checked > 0 {
+			g.rejections.Text = stats.String()
+		}
+		g.placeToolbar()
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	eqt "github.com/toantht/texturegen/equation"
+)
+
+// viewport is the part of the plane an equation is rendered over. The
+// screen is mapped to [-1,1]², rotated by rotation radians, scaled by scale
+// and moved to center.
+type viewport struct {
+	centerX, centerY float32
+	scale            float32
+	rotation         float32
+}
+
+var defaultViewport = viewport{scale: 1}
+
+// toDomain maps the screen coordinates u and v, both in [-1,1], to the point
+// the equation is evaluated at.
+func (vp viewport) toDomain(u, v float32) (float32, float32) {
+	sin, cos := math.Sincos(float64(vp.rotation))
+	s, c := float32(sin), float32(cos)
+	x := vp.centerX + vp.scale*(u*c-v*s)
+	y := vp.centerY + vp.scale*(u*s+v*c)
+	return x, y
+}
+
+// screenToDomain maps the point x, y, in pixels, of a width by height screen.
+func (vp viewport) screenToDomain(x, y float32, width, height int) (float32, float32) {
+	return vp.toDomain(x/float32(width)*2-1, y/float32(height)*2-1)
+}
+
+// bounds returns the smallest rectangle of the plane holding the viewport.
+func (vp viewport) bounds() (eqt.Interval, eqt.Interval) {
+	x0, y0 := vp.toDomain(-1, -1)
+	x, y := eqt.Point(x0), eqt.Point(y0)
+	for _, corner := range [][2]float32{{1, -1}, {-1, 1}, {1, 1}} {
+		cx, cy := vp.toDomain(corner[0], corner[1])
+		x.Low, x.High = min(x.Low, cx), max(x.High, cx)
+		y.Low, y.High = min(y.Low, cy), max(y.High, cy)
+	}
+	return x, y
+}
+
+// zoomAt scales the viewport by factor, keeping the point under the pixel
+// x, y in place.
+func (vp *viewport) zoomAt(x, y, width, height int, factor float32) {
+	beforeX, beforeY := vp.screenToDomain(float32(x), float32(y), width, height)
+	vp.scale *= factor
+	afterX, afterY := vp.screenToDomain(float32(x), float32(y), width, height)
+	vp.centerX += beforeX - afterX
+	vp.centerY += beforeY - afterY
+}
+
+// pan moves the viewport so the image follows a drag of dx, dy pixels.
+func (vp *viewport) pan(dx, dy, width, height int) {
+	x0, y0 := vp.toDomain(0, 0)
+	x1, y1 := vp.toDomain(float32(dx)/float32(width)*2, float32(dy)/float32(height)*2)
+	vp.centerX -= x1 - x0
+	vp.centerY -= y1 - y0
+}
+
+func (vp *viewport) rotate(angle float32) {
+	vp.rotation += angle
+}
+
+// setting is the viewport as stored in .eqt files.
+func (vp viewport) setting() eqt.Setting {
+	args := make([]string, 0, 4)
+	for _, f := range []float32{vp.centerX, vp.centerY, vp.scale, vp.rotation} {
+		args = append(args, formatArg(f))
+	}
+	return eqt.Setting{Name: "Viewport", Args: args}
+}
+
+func parseViewport(s eqt.Setting) (viewport, error) {
+	if len(s.Args) != 4 {
+		return viewport{}, fmt.Errorf("viewport takes 4 arguments, got %d", len(s.Args))
+	}
+	values := make([]float32, 4)
+	for i, arg := range s.Args {
+		value, err := strconv.ParseFloat(arg, 32)
+		if err != nil {
+			return viewport{}, fmt.Errorf("viewport: %w", err)
+		}
+		values[i] = float32(value)
+	}
+	return viewport{values[0], values[1], values[2], values[3]}, nil
+}